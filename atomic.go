@@ -0,0 +1,134 @@
+package psx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const backupSuffix = ".bak"
+
+// An atomicFile is a temporary file that replaces the named file when
+// committed, or is removed if aborted.
+type atomicFile struct {
+	*os.File
+	name   string
+	backup bool
+}
+
+// A FileOption changes how CreateFile and OpenForUpdate replace a file.
+type FileOption func(*atomicFile)
+
+// WithBackup keeps the previous version of the file, if there is one, with a
+// ".bak" suffix added to its name. Any older backup is replaced.
+func WithBackup() FileOption {
+	return func(af *atomicFile) {
+		af.backup = true
+	}
+}
+
+func createAtomic(name string, opts ...FileOption) (*atomicFile, error) {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary file: %w", err)
+	}
+
+	// Keep the permissions of any existing file
+	mode := os.FileMode(0o644) //nolint:gomnd
+	if fi, err := os.Stat(name); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	af := &atomicFile{File: f, name: name}
+	for _, opt := range opts {
+		opt(af)
+	}
+
+	if err := f.Chmod(mode); err != nil {
+		af.abort()
+
+		return nil, fmt.Errorf("unable to chmod: %w", err)
+	}
+
+	return af, nil
+}
+
+func (af *atomicFile) abort() {
+	_ = af.File.Close()
+	_ = os.Remove(af.File.Name())
+}
+
+func (af *atomicFile) commit() error {
+	if err := af.File.Sync(); err != nil {
+		af.abort()
+
+		return fmt.Errorf("unable to sync: %w", err)
+	}
+
+	if err := af.File.Close(); err != nil {
+		_ = os.Remove(af.File.Name())
+
+		return fmt.Errorf("unable to close: %w", err)
+	}
+
+	if af.backup {
+		if err := backupFile(af.name); err != nil {
+			_ = os.Remove(af.File.Name())
+
+			return err
+		}
+	}
+
+	if err := os.Rename(af.File.Name(), af.name); err != nil {
+		_ = os.Remove(af.File.Name())
+
+		return fmt.Errorf("unable to rename: %w", err)
+	}
+
+	syncDir(filepath.Dir(af.name))
+
+	return nil
+}
+
+// backupFile copies the named file, if it exists, to a file with the backup
+// suffix added. The copy is itself atomic so an interrupted backup doesn't
+// destroy the previous one.
+func backupFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		return fmt.Errorf("unable to open: %w", err)
+	}
+	defer f.Close()
+
+	af, err := createAtomic(name + backupSuffix)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(af, f); err != nil {
+		af.abort()
+
+		return fmt.Errorf("unable to copy: %w", err)
+	}
+
+	return af.commit()
+}
+
+// syncDir makes a rename within dir durable. Not every platform supports
+// syncing a directory so any error is ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+
+	_ = d.Sync()
+}
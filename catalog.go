@@ -0,0 +1,270 @@
+package psx
+
+import (
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+)
+
+// The delay between icon frames in hundredths of a second.
+const iconDelay = 25
+
+//nolint:gochecknoglobals,lll
+var catalogTemplate = template.Must(template.New("catalog").Parse(`{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: middle; }
+img.icon { width: 32px; height: 32px; image-rendering: pixelated; }
+</style>
+</head>
+<body>
+<h1>{{.}}</h1>
+{{end}}{{define "index"}}{{template "head" "Memory cards"}}<table>
+<thead><tr><th>Memory card</th><th>Format</th><th>Saves</th><th>Free blocks</th></tr></thead>
+<tbody>
+{{range .}}<tr><td><a href="{{.Page}}"><img src="{{.Screenshot}}" alt=""><br>{{.Name}}</a></td><td>{{.Format}}</td><td>{{len .Saves}}</td><td>{{.Free}}</td></tr>
+{{end}}</tbody>
+</table>
+</body>
+</html>
+{{end}}{{define "card"}}{{template "head" .Name}}<p><a href="index.html">All memory cards</a></p>
+<p><img src="{{.Screenshot}}" alt=""></p>
+<table>
+<thead><tr><th>Icon</th><th>Title</th><th>Serial</th><th>Game</th><th>Name</th><th>Blocks</th><th>Download</th></tr></thead>
+<tbody>
+{{range .Saves}}<tr><td><img class="icon" src="{{.Icon}}" alt=""></td><td>{{.Title}}</td><td>{{.Serial}}</td><td>{{.Game}}</td><td>{{.Name}}</td><td>{{len .Blocks}} ({{range $i, $b := .Blocks}}{{if $i}}, {{end}}{{$b}}{{end}})</td><td>{{range .Downloads}}<a href="{{.Path}}" download="{{.Name}}">{{.Format}}</a> {{end}}</td></tr>
+{{end}}</tbody>
+</table>
+</body>
+</html>
+{{end}}`))
+
+// A CatalogCard is a memory card to include in a catalogue.
+type CatalogCard struct {
+	// Name is shown as the title of the page for the memory card, such as
+	// the name of the memory card image.
+	Name   string
+	Reader *Reader
+}
+
+type catalogDownload struct {
+	Path, Name, Format string
+}
+
+type catalogSave struct {
+	Title, Serial, Game, Name, Icon string
+	Blocks                          []int
+	Downloads                       []catalogDownload
+}
+
+type catalogCard struct {
+	Name, Page, Screenshot string
+	Format                 Format
+	Free                   int
+	Saves                  []catalogSave
+}
+
+type catalogWriter struct {
+	create func(string) (io.WriteCloser, error)
+	game   func(string) string
+}
+
+// file calls fn to write the named file of the catalogue.
+func (cw *catalogWriter) file(name string, fn func(io.Writer) error) error {
+	w, err := cw.create(name)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(w); err != nil {
+		w.Close()
+
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("unable to close %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// encodeIconGIF encodes the icon frames as an animated GIF.
+func encodeIconGIF(w io.Writer, frames []image.Image) error {
+	g := new(gif.GIF)
+
+	for _, frame := range frames {
+		img, ok := frame.(*image.Paletted)
+		if !ok {
+			continue
+		}
+
+		g.Image = append(g.Image, img)
+		g.Delay = append(g.Delay, iconDelay)
+		g.Disposal = append(g.Disposal, gif.DisposalBackground)
+	}
+
+	if len(g.Image) == 0 {
+		// Files without an icon get a blank one
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, iconSize, iconSize), []color.Color{color.Transparent}))
+		g.Delay = append(g.Delay, 0)
+	}
+
+	if err := gif.EncodeAll(w, g); err != nil {
+		return fmt.Errorf("unable to encode icon: %w", err)
+	}
+
+	return nil
+}
+
+// downloadName makes name safe for use as a filename.
+func downloadName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r > '~' || strings.ContainsRune(`"*/:<>?\|`, r) {
+			return '_'
+		}
+
+		return r
+	}, name)
+}
+
+// saves writes the icon and downloads for each file on the memory card read
+// by r to dir, and adds each file to cc.
+//
+//nolint:funlen
+func (cw *catalogWriter) saves(cc *catalogCard, dir string, r *Reader) error {
+	for i, f := range r.File {
+		title, err := f.Title()
+		if err != nil {
+			return err
+		}
+
+		icon, err := f.Icon()
+		if err != nil {
+			return err
+		}
+
+		b, err := readFile(f)
+		if err != nil {
+			return err
+		}
+
+		b = ExportSave(b)
+
+		base := path.Join(dir, fmt.Sprintf("save%d", i+1))
+		save := catalogSave{
+			Title:  title,
+			Serial: f.Serial(),
+			Name:   f.Name,
+			Icon:   base + ".gif",
+			Blocks: f.blocks(),
+			Downloads: []catalogDownload{
+				{base + ".mcs", downloadName(f.Name) + ".mcs", "MCS"},
+				{base + ".mcd", downloadName(f.Name) + ".mcd", "Memory card"},
+			},
+		}
+
+		if cw.game != nil {
+			save.Game = cw.game(save.Serial)
+		}
+
+		if err := cw.file(save.Icon, func(w io.Writer) error {
+			return encodeIconGIF(w, icon)
+		}); err != nil {
+			return err
+		}
+
+		if err := cw.file(base+".mcs", func(w io.Writer) error {
+			_, err := w.Write(b)
+
+			return err //nolint:wrapcheck
+		}); err != nil {
+			return err
+		}
+
+		if err := cw.file(base+".mcd", func(w io.Writer) error {
+			mw, err := NewWriter(w)
+			if err != nil {
+				return err
+			}
+
+			if err := mw.add(b); err != nil {
+				return err
+			}
+
+			return mw.Close()
+		}); err != nil {
+			return err
+		}
+
+		cc.Free -= len(save.Blocks)
+		cc.Saves = append(cc.Saves, save)
+	}
+
+	return nil
+}
+
+// WriteCatalog writes a static HTML catalogue of the memory cards. There is an
+// index page listing each memory card, and a page for each memory card listing
+// each file with its animated icon, title, serial number, the blocks it uses,
+// and links to download it either as a single save in the MCS format or on a
+// memory card of its own. MCS is the only single save format written as it's
+// the one read by every common memory card manager. If game is not nil it is called with the serial
+// number of each file and should return the name of the game, or an empty
+// string if it isn't known. Each file of the catalogue, including the index
+// page "index.html", is created by calling create with its slash-separated
+// path.
+func WriteCatalog(create func(string) (io.WriteCloser, error), game func(string) string, cards ...CatalogCard) error {
+	cw := &catalogWriter{create, game}
+	catalog := make([]*catalogCard, 0, len(cards))
+
+	for i, card := range cards {
+		dir := fmt.Sprintf("card%d", i+1)
+
+		cc := &catalogCard{
+			Name:       card.Name,
+			Page:       dir + ".html",
+			Screenshot: dir + ".png",
+			Format:     card.Reader.Format(),
+			Free:       numBlocks,
+		}
+
+		if err := cw.saves(cc, dir, card.Reader); err != nil {
+			return err
+		}
+
+		img, err := Render(card.Reader, -1)
+		if err != nil {
+			return err
+		}
+
+		if err := cw.file(cc.Screenshot, func(w io.Writer) error {
+			return png.Encode(w, img) //nolint:wrapcheck
+		}); err != nil {
+			return err
+		}
+
+		if err := cw.file(cc.Page, func(w io.Writer) error {
+			return catalogTemplate.ExecuteTemplate(w, "card", cc) //nolint:wrapcheck
+		}); err != nil {
+			return err
+		}
+
+		catalog = append(catalog, cc)
+	}
+
+	return cw.file("index.html", func(w io.Writer) error {
+		return catalogTemplate.ExecuteTemplate(w, "index", catalog) //nolint:wrapcheck
+	})
+}
@@ -0,0 +1,101 @@
+package psx_test
+
+import (
+	"bytes"
+	"fmt"
+	"image/gif"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
+)
+
+type nopWriteCloser struct {
+	*bytes.Buffer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+//nolint:funlen
+func TestWriteCatalog(t *testing.T) {
+	t.Parallel()
+
+	var cards []psx.CatalogCard
+
+	for _, file := range []string{"m1.mcd", "MemoryCard2-1.mcd"} {
+		rc, err := psx.OpenReader(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+
+		cards = append(cards, psx.CatalogCard{Name: file, Reader: &rc.Reader})
+	}
+
+	files := make(map[string]*bytes.Buffer)
+
+	if err := psx.WriteCatalog(func(name string) (io.WriteCloser, error) {
+		files[name] = new(bytes.Buffer)
+
+		return nopWriteCloser{files[name]}, nil
+	}, func(serial string) string {
+		if serial == "SLES-00024" {
+			return "Tomb Raider (Europe)"
+		}
+
+		return ""
+	}, cards...); err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.Contains(t, files, "index.html") {
+		return
+	}
+
+	index := files["index.html"].String()
+	assert.Contains(t, index, `<a href="card1.html">`)
+	assert.Contains(t, index, "MemoryCard2-1.mcd")
+
+	assert.Contains(t, files, "card1.png")
+	assert.Contains(t, files, "card2.png")
+
+	page := files["card2.html"].String()
+	assert.Contains(t, page, "Tomb Raider (Europe)")
+	assert.Contains(t, page, "[TEKKEN 3]")
+	assert.Contains(t, page, "SCES-01237")
+	assert.Contains(t, page, `download="BESLES-00024TOMBRAID.mcs"`)
+
+	// The first file uses five blocks
+	assert.Contains(t, page, "5 (0, 1, 2, 3, 4)")
+
+	for i, f := range cards[1].Reader.File {
+		icon, err := f.Icon()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		base := fmt.Sprintf("card2/save%d", i+1)
+
+		g, err := gif.DecodeAll(bytes.NewReader(files[base+".gif"].Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Len(t, g.Image, len(icon))
+
+		r, err := psx.NewReader(bytes.NewReader(files[base+".mcd"].Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if assert.Len(t, r.File, 1) {
+			assert.Equal(t, f.Name, r.File[0].Name)
+		}
+
+		assert.Len(t, files[base+".mcs"].Bytes(), int(f.Size))
+	}
+}
@@ -0,0 +1,299 @@
+package psx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	firstDirectoryFrame = 1
+	firstUnusedFrame    = firstDirectoryFrame + numBlocks
+	trailingFrame       = blockSize/frameSize - 1
+)
+
+type checker struct {
+	b        []byte
+	repair   bool
+	dirty    map[int]struct{}
+	problems []error
+}
+
+func (c *checker) frame(i int) []byte {
+	return c.b[i*frameSize : (i+1)*frameSize]
+}
+
+func (c *checker) dataBlock(i int) []byte {
+	return c.b[(i+reservedBlocks)*blockSize : (i+reservedBlocks+1)*blockSize]
+}
+
+func (c *checker) report(frame, block int, err error) {
+	c.problems = append(c.problems, locate(err, frame, block))
+
+	if frame >= 0 {
+		c.dirty[frame] = struct{}{}
+	}
+}
+
+func (c *checker) allocation(i int) byte {
+	return c.frame(firstDirectoryFrame + i)[0]
+}
+
+func (c *checker) setAllocation(i int, ab byte) {
+	if c.repair {
+		c.frame(firstDirectoryFrame + i)[0] = ab
+	}
+}
+
+func (c *checker) size(i int) uint32 {
+	return binary.LittleEndian.Uint32(c.frame(firstDirectoryFrame + i)[4:])
+}
+
+func (c *checker) setSize(i int, size uint32) {
+	if c.repair {
+		binary.LittleEndian.PutUint32(c.frame(firstDirectoryFrame + i)[4:], size)
+	}
+}
+
+func (c *checker) link(i int) uint16 {
+	return binary.LittleEndian.Uint16(c.frame(firstDirectoryFrame + i)[8:])
+}
+
+func (c *checker) setLink(i int, lo uint16) {
+	if c.repair {
+		binary.LittleEndian.PutUint16(c.frame(firstDirectoryFrame + i)[8:], lo)
+	}
+}
+
+func (c *checker) filename(i int) string {
+	f := c.frame(firstDirectoryFrame + i)
+
+	return cstring(f[filenameOffset : filenameOffset+filenameSize])
+}
+
+func (c *checker) isLink(i int) bool {
+	ab := c.allocation(i)
+
+	return ab == blockMiddleLink || ab == blockLastLink
+}
+
+func (c *checker) free(i int) {
+	c.setAllocation(i, blockAvailable)
+	c.setLink(i, lastLink)
+}
+
+func (c *checker) checkHeaderFrame(i int) {
+	if f := c.frame(i); !bytes.HasPrefix(f, headerSignature[:]) {
+		c.report(i, -1, ErrBadHeaderSignature)
+
+		if c.repair {
+			copy(f, headerSignature[:])
+		}
+	}
+
+	c.checkChecksum(i, -1, ErrBadHeaderChecksum)
+}
+
+func (c *checker) checkChecksum(i, block int, err error) {
+	if f, sum := c.frame(i), checksum(c.frame(i)[:frameSize-1]); sum[0] != f[frameSize-1] {
+		c.report(i, block, &ChecksumError{Expected: sum[0], Actual: f[frameSize-1], Err: err})
+	}
+}
+
+func (c *checker) checkChecksums() {
+	c.checkHeaderFrame(0)
+
+	for i := 0; i < numBlocks; i++ {
+		c.checkChecksum(firstDirectoryFrame+i, i, ErrBadDirectoryChecksum)
+	}
+
+	for i := 0; i < numUnusedFrames; i++ {
+		c.checkChecksum(firstUnusedFrame+i, -1, ErrBadUnusedChecksum)
+	}
+
+	c.checkHeaderFrame(trailingFrame)
+}
+
+func (c *checker) checkAllocations() {
+	for i := 0; i < numBlocks; i++ {
+		switch c.allocation(i) {
+		case blockFirstLink, blockMiddleLink, blockLastLink, blockAvailable:
+		case blockDeletedFirstLink, blockDeletedMiddleLink, blockDeletedLastLink:
+		default:
+			c.report(firstDirectoryFrame+i, i, ErrBadAllocation)
+			c.free(i)
+		}
+	}
+}
+
+func (c *checker) checkChain(i int, owner []int) {
+	blocks := 1
+	last := i
+
+	for lo := c.link(last); lo != lastLink; lo = c.link(last) {
+		next := int(lo)
+
+		if next >= numBlocks || owner[next] >= 0 || !c.isLink(next) {
+			c.report(firstDirectoryFrame+last, last, ErrBadLink)
+			c.setLink(last, lastLink)
+
+			if last != i {
+				c.setAllocation(last, blockLastLink)
+			}
+
+			break
+		}
+
+		if c.allocation(last) == blockLastLink {
+			c.report(firstDirectoryFrame+last, last, ErrBadLink)
+			c.setAllocation(last, blockMiddleLink)
+		}
+
+		owner[next] = i
+		last = next
+		blocks++
+	}
+
+	if last != i && c.allocation(last) != blockLastLink && c.link(last) == lastLink {
+		c.report(firstDirectoryFrame+last, last, ErrBadLink)
+		c.setAllocation(last, blockLastLink)
+	}
+
+	if size := uint32(blocks * blockSize); c.size(i) != size {
+		c.report(firstDirectoryFrame+i, i, &LengthError{
+			Name:     c.filename(i),
+			Expected: int64(size),
+			Actual:   int64(c.size(i)),
+		})
+		c.setSize(i, size)
+	}
+
+	if !bytes.HasPrefix(c.dataBlock(i), dataSignature[:]) {
+		c.report(-1, i, ErrBadDataSignature)
+	}
+}
+
+func (c *checker) checkChains() {
+	owner := make([]int, numBlocks)
+	for i := range owner {
+		owner[i] = -1
+	}
+
+	for i := 0; i < numBlocks; i++ {
+		if c.allocation(i) == blockFirstLink {
+			owner[i] = i
+		}
+	}
+
+	for i := 0; i < numBlocks; i++ {
+		if owner[i] == i {
+			c.checkChain(i, owner)
+		}
+	}
+
+	for i := 0; i < numBlocks; i++ {
+		if owner[i] < 0 && c.isLink(i) {
+			c.report(firstDirectoryFrame+i, i, ErrOrphanedBlock)
+			c.free(i)
+		}
+	}
+}
+
+func (c *checker) check() {
+	// Checksums are verified first so that any repairs don't cause them
+	// to be reported as bad
+	c.checkChecksums()
+	c.checkAllocations()
+	c.checkChains()
+
+	if c.repair {
+		for i := range c.dirty {
+			f := c.frame(i)
+			copy(f[frameSize-1:], checksum(f[:frameSize-1]))
+		}
+	}
+}
+
+// readCard reads a memory card image in any supported format from r and
+// returns the memory card within it. Anything unrecognised is treated as a
+// raw memory card image.
+func readCard(r io.Reader) (Format, []byte, error) {
+	f, b, offset, err := readImage(r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return f, b[offset : offset+cardSize], nil
+}
+
+// readImage is like readCard but returns the whole image along with the
+// offset of the memory card within it.
+func readImage(r io.Reader) (Format, []byte, int, error) {
+	b, err := io.ReadAll(io.LimitReader(r, maxImageSize+1))
+	if err != nil {
+		return 0, nil, 0, fmt.Errorf("unable to read memory card: %w", err)
+	}
+
+	f, offset, ok, err := detectFormat(bytes.NewReader(b), int64(len(b)), make([]byte, frameSize))
+	if err != nil {
+		return 0, nil, 0, err
+	}
+
+	if ok {
+		return f, b, int(offset), nil
+	}
+
+	switch {
+	case len(b) < cardSize:
+		return 0, nil, 0, ErrInvalidLength
+	case len(b) > cardSize:
+		return 0, nil, 0, ErrTrailingBytes
+	}
+
+	return FormatRaw, b, 0, nil
+}
+
+// Check reads the memory card image in any supported format from r and
+// returns all of the problems found with it. Each problem is a *FrameError
+// identifying the affected frame and block. An error is only returned if the
+// image couldn't be read.
+func Check(r io.Reader) ([]error, error) {
+	_, b, err := readCard(r)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &checker{b: b, dirty: make(map[int]struct{})}
+	c.check()
+
+	return c.problems, nil
+}
+
+// Repair reads the memory card image in any supported format from r and
+// writes a repaired copy to w in the same format. The problems found are
+// returned in the same manner as Check. Broken checksums, links and sizes
+// are fixed and orphaned blocks are freed, however saves with corrupt data
+// are left as-is so running Check on the repaired copy may still find some
+// problems. For FormatSRM, only the first memory card is repaired and
+// everything else in the image is copied unaltered.
+func Repair(w io.Writer, r io.Reader) ([]error, error) {
+	f, b, offset, err := readImage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// The memory card is repaired in place within the image
+	c := &checker{b: b[offset : offset+cardSize], repair: true, dirty: make(map[int]struct{})}
+	c.check()
+
+	if b, err = f.wrap(c.b, b[:offset], b[offset+cardSize:]); err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(b); err != nil {
+		return nil, fmt.Errorf("unable to write memory card: %w", err)
+	}
+
+	return c.problems, nil
+}
@@ -0,0 +1,222 @@
+package psx_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	frameSize = 128
+	blockSize = 8192
+)
+
+func directoryFrame(b []byte, block int) []byte {
+	return b[(block+1)*frameSize : (block+2)*frameSize]
+}
+
+func fixChecksum(f []byte) {
+	f[frameSize-1] = 0
+	for _, x := range f[:frameSize-1] {
+		f[frameSize-1] ^= x
+	}
+}
+
+func errorLocation(err error) (int, int, bool) {
+	var (
+		ce *psx.ChecksumError
+		fe *psx.FrameError
+	)
+
+	switch {
+	case errors.As(err, &ce):
+		return ce.Frame, ce.Block, true
+	case errors.As(err, &fe):
+		return fe.Frame, fe.Block, true
+	}
+
+	return 0, 0, false
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	files := []string{"blank.mcd", "m1.mcd", "MemoryCard2-1.mcd"}
+
+	for _, file := range files {
+		file := file
+		t.Run(file, func(t *testing.T) {
+			t.Parallel()
+
+			f, err := os.Open(filepath.Join("testdata", file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			problems, err := psx.Check(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Empty(t, problems)
+		})
+	}
+}
+
+//nolint:funlen
+func TestRepair(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name    string
+		corrupt func([]byte)
+		err     error
+		frame   int
+		block   int
+	}{
+		{
+			name: "header checksum",
+			corrupt: func(b []byte) {
+				b[frameSize-1] ^= 0xff
+			},
+			err:   psx.ErrBadHeaderChecksum,
+			frame: 0,
+			block: -1,
+		},
+		{
+			name: "directory checksum",
+			corrupt: func(b []byte) {
+				directoryFrame(b, 2)[frameSize-1] ^= 0xff
+			},
+			err:   psx.ErrBadDirectoryChecksum,
+			frame: 3,
+			block: 2,
+		},
+		{
+			name: "link out of range",
+			corrupt: func(b []byte) {
+				f := directoryFrame(b, 3)
+				binary.LittleEndian.PutUint16(f[8:], 20)
+				fixChecksum(f)
+			},
+			err:   psx.ErrBadLink,
+			frame: 4,
+			block: 3,
+		},
+		{
+			name: "link cycle",
+			corrupt: func(b []byte) {
+				f := directoryFrame(b, 3)
+				binary.LittleEndian.PutUint16(f[8:], 1)
+				fixChecksum(f)
+			},
+			err:   psx.ErrBadLink,
+			frame: 4,
+			block: 3,
+		},
+		{
+			name: "wrong size",
+			corrupt: func(b []byte) {
+				f := directoryFrame(b, 0)
+				binary.LittleEndian.PutUint32(f[4:], blockSize)
+				fixChecksum(f)
+			},
+			err:   psx.ErrInvalidLength,
+			frame: 1,
+			block: 0,
+		},
+	}
+
+	b, err := os.ReadFile(filepath.Join("testdata", "MemoryCard2-1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, table := range tables {
+		table := table
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			corrupt := append([]byte{}, b...)
+			table.corrupt(corrupt)
+
+			problems, err := psx.Check(bytes.NewReader(corrupt))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if assert.NotEmpty(t, problems) {
+				assert.ErrorIs(t, problems[0], table.err)
+
+				frame, block, ok := errorLocation(problems[0])
+				if assert.True(t, ok) {
+					assert.Equal(t, table.frame, frame)
+					assert.Equal(t, table.block, block)
+				}
+			}
+
+			buf := new(bytes.Buffer)
+
+			if _, err := psx.Repair(buf, bytes.NewReader(corrupt)); err != nil {
+				t.Fatal(err)
+			}
+
+			problems, err = psx.Check(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Empty(t, problems)
+
+			if _, err := psx.NewReader(buf); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRepairSRM(t *testing.T) {
+	t.Parallel()
+
+	b, err := os.ReadFile(filepath.Join("testdata", "MemoryCard2-1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blank, err := os.ReadFile(filepath.Join("testdata", "blank.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := append([]byte{}, b...)
+	directoryFrame(corrupt, 0)[frameSize-1] ^= 0xff
+
+	prefix := bytes.Repeat([]byte{0xaa}, 0x200)
+	srm := bytes.Join([][]byte{prefix, corrupt, blank}, nil)
+
+	buf := new(bytes.Buffer)
+
+	problems, err := psx.Repair(buf, bytes.NewReader(srm))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEmpty(t, problems)
+
+	// Only the memory card is altered, everything around it is kept
+	assert.Equal(t, bytes.Join([][]byte{prefix, b, blank}, nil), buf.Bytes())
+
+	r, err := psx.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, psx.FormatSRM, r.Format())
+}
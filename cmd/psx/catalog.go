@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bodgit/psx"
+)
+
+// readGames reads a list of game names, one per line as a serial number and
+// the name of the game separated by a tab. Blank lines and lines starting
+// with # are ignored.
+func readGames(name string) (map[string]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open: %w", err)
+	}
+	defer f.Close()
+
+	games := make(map[string]string)
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if fields := strings.SplitN(line, "\t", 2); len(fields) == 2 { //nolint:gomnd
+			games[strings.TrimSpace(fields[0])] = strings.TrimSpace(fields[1])
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", name, err)
+	}
+
+	return games, nil
+}
+
+func openCatalogCards(target checkTarget) ([]psx.CatalogCard, error) {
+	f, err := os.Open(target.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open: %w", err)
+	}
+	defer f.Close()
+
+	cr, err := psx.NewContainerReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", target.path, err)
+	}
+
+	cards := make([]psx.CatalogCard, 0, len(cr.Page))
+
+	for i, r := range cr.Page {
+		name := target.rel
+		if len(cr.Page) > 1 {
+			name = fmt.Sprintf("%s (page %d)", name, i+1)
+		}
+
+		cards = append(cards, psx.CatalogCard{Name: name, Reader: r})
+	}
+
+	return cards, nil
+}
+
+func catalog(fs *flag.FlagSet, args []string, _ io.Writer) error {
+	gamesFile := fs.String("games", "", "read game names from `file` of tab-separated serial numbers and names")
+
+	if err := parseArgs(fs, args, 2, -1); err != nil { //nolint:gomnd
+		return err
+	}
+
+	var game func(string) string
+
+	if *gamesFile != "" {
+		games, err := readGames(*gamesFile)
+		if err != nil {
+			return err
+		}
+
+		game = func(serial string) string {
+			return games[serial]
+		}
+	}
+
+	targets, err := findCards(fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+
+	var cards []psx.CatalogCard
+
+	for _, target := range targets {
+		c, err := openCatalogCards(target)
+		if err != nil {
+			return err
+		}
+
+		cards = append(cards, c...)
+	}
+
+	dir := fs.Arg(0)
+
+	return psx.WriteCatalog(func(name string) (io.WriteCloser, error) { //nolint:wrapcheck
+		name = filepath.Join(dir, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(name), 0o777); err != nil { //nolint:gosec
+			return nil, fmt.Errorf("unable to create directory: %w", err)
+		}
+
+		f, err := os.Create(name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create: %w", err)
+		}
+
+		return f, nil
+	}, game, cards...)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/bodgit/psx"
+)
+
+var errCorrupt = errors.New("corrupt memory cards found")
+
+type checkTarget struct {
+	path string
+	rel  string
+}
+
+// findCards expands any directories in args to the memory card images found
+// within them. Plain files are always checked regardless of their content.
+func findCards(args []string) ([]checkTarget, error) {
+	var targets []checkTarget
+
+	for _, arg := range args {
+		fi, err := os.Stat(arg)
+		if err != nil {
+			return nil, fmt.Errorf("unable to stat: %w", err)
+		}
+
+		if !fi.IsDir() {
+			targets = append(targets, checkTarget{arg, filepath.Base(arg)})
+
+			continue
+		}
+
+		root := arg
+
+		if err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+
+			ok, err := isMemoryCard(path)
+			if err != nil || !ok {
+				return err
+			}
+
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return fmt.Errorf("unable to compute relative path: %w", err)
+			}
+
+			targets = append(targets, checkTarget{path, rel})
+
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("unable to walk %s: %w", root, err)
+		}
+	}
+
+	return targets, nil
+}
+
+// writeFile writes b to a temporary file alongside name and renames it into
+// place so name is never left partially written.
+func writeFile(name string, b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %w", err)
+	}
+
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+
+		return fmt.Errorf("unable to write: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unable to close: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), name); err != nil {
+		return fmt.Errorf("unable to rename: %w", err)
+	}
+
+	return nil
+}
+
+func repairFile(in, out string) error {
+	f, err := os.Open(in)
+	if err != nil {
+		return fmt.Errorf("unable to open: %w", err)
+	}
+	defer f.Close()
+
+	buf := new(bytes.Buffer)
+
+	if _, err := psx.Repair(buf, f); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if err := os.MkdirAll(filepath.Dir(out), 0o777); err != nil { //nolint:gosec
+		return fmt.Errorf("unable to create directory: %w", err)
+	}
+
+	return writeFile(out, buf.Bytes())
+}
+
+func checkFile(name string) ([]error, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open: %w", err)
+	}
+	defer f.Close()
+
+	return psx.Check(f) //nolint:wrapcheck
+}
+
+//nolint:cyclop,funlen
+func check(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	output := fs.String("o", "",
+		"write repaired copies of corrupt cards to `path`, a directory if checking more than one card")
+	inPlace := fs.Bool("w", false, "repair corrupt cards in place")
+	quiet := fs.Bool("q", false, "only report corrupt cards")
+
+	if err := parseArgs(fs, args, 1, -1); err != nil {
+		return err
+	}
+
+	if *output != "" && *inPlace {
+		return errUsage
+	}
+
+	targets, err := findCards(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	var corrupt, repaired int
+
+	for _, target := range targets {
+		problems, err := checkFile(target.path)
+		if err != nil {
+			corrupt++
+
+			fmt.Fprintf(stdout, "%s: %v\n", target.path, err)
+
+			continue
+		}
+
+		if len(problems) == 0 {
+			if !*quiet {
+				fmt.Fprintf(stdout, "%s: ok\n", target.path)
+			}
+
+			continue
+		}
+
+		corrupt++
+
+		for _, problem := range problems {
+			fmt.Fprintf(stdout, "%s: %v\n", target.path, problem)
+		}
+
+		var out string
+
+		switch {
+		case *inPlace:
+			out = target.path
+		case *output != "" && len(targets) > 1:
+			out = filepath.Join(*output, target.rel)
+		case *output != "":
+			out = *output
+		default:
+			continue
+		}
+
+		if err := repairFile(target.path, out); err != nil {
+			fmt.Fprintf(stdout, "%s: unable to repair: %v\n", target.path, err)
+
+			continue
+		}
+
+		repaired++
+
+		fmt.Fprintf(stdout, "%s: repaired to %s\n", target.path, out)
+	}
+
+	fmt.Fprintf(stdout, "%d checked, %d corrupt, %d repaired\n", len(targets), corrupt, repaired)
+
+	if corrupt > 0 {
+		return fmt.Errorf("%w: %d of %d", errCorrupt, corrupt, len(targets))
+	}
+
+	return nil
+}
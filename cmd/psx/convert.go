@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bodgit/psx"
+)
+
+var (
+	errNoFormat       = errors.New("unable to determine output format, use -f")
+	errConvertFailed  = errors.New("conversion failed")
+	errOutputConflict = errors.New("output already written by another input")
+)
+
+func convertFile(in, out string, f psx.Format) error {
+	src, err := os.Open(in)
+	if err != nil {
+		return fmt.Errorf("unable to open: %w", err)
+	}
+	defer src.Close()
+
+	// Convert into memory first so a bad input doesn't leave a partial output
+	buf := new(bytes.Buffer)
+
+	if err := psx.Convert(buf, f, src); err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+
+	if err := os.WriteFile(out, buf.Bytes(), 0o666); err != nil { //nolint:gosec
+		return fmt.Errorf("unable to write: %w", err)
+	}
+
+	return nil
+}
+
+func isMemoryCard(name string) (bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return false, fmt.Errorf("unable to open: %w", err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return false, fmt.Errorf("unable to stat: %w", err)
+	}
+
+	ok, err := psx.DetectMemoryCard(f, fi.Size())
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return ok, nil
+}
+
+type convertSummary struct {
+	converted, skipped, failed int
+}
+
+//nolint:cyclop,funlen
+func convertTree(in, out string, f psx.Format, stdout io.Writer) error {
+	var s convertSummary
+
+	written := make(map[string]string)
+
+	err := filepath.WalkDir(in, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			// Don't descend into the output if it's within the input
+			if path == out && path != in {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		ok, err := isMemoryCard(path)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			s.skipped++
+
+			return nil
+		}
+
+		rel, err := filepath.Rel(in, path)
+		if err != nil {
+			return fmt.Errorf("unable to compute relative path: %w", err)
+		}
+
+		target := filepath.Join(out, strings.TrimSuffix(rel, filepath.Ext(rel))+f.Extension())
+
+		if prev, ok := written[target]; ok {
+			s.failed++
+
+			fmt.Fprintf(stdout, "FAIL %s: %v: %s\n", path, errOutputConflict, prev)
+
+			return nil
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0o777); err != nil { //nolint:gosec
+			return fmt.Errorf("unable to create directory: %w", err)
+		}
+
+		if err := convertFile(path, target, f); err != nil {
+			s.failed++
+
+			fmt.Fprintf(stdout, "FAIL %s: %v\n", path, err)
+
+			return nil
+		}
+
+		written[target] = path
+		s.converted++
+
+		fmt.Fprintf(stdout, "ok   %s -> %s\n", path, target)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to walk %s: %w", in, err)
+	}
+
+	fmt.Fprintf(stdout, "%d converted, %d skipped, %d failed\n", s.converted, s.skipped, s.failed)
+
+	if s.failed > 0 {
+		return fmt.Errorf("%w: %d of %d", errConvertFailed, s.failed, s.converted+s.failed)
+	}
+
+	return nil
+}
+
+func convert(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	name := fs.String("f", "",
+		"output `format`: raw, dexdrive or vmp (default from output extension, required for a directory)")
+
+	if err := parseArgs(fs, args, 2, 2); err != nil { //nolint:gomnd
+		return err
+	}
+
+	in, out := filepath.Clean(fs.Arg(0)), filepath.Clean(fs.Arg(1))
+
+	fi, err := os.Stat(in)
+	if err != nil {
+		return fmt.Errorf("unable to stat: %w", err)
+	}
+
+	var (
+		f  psx.Format
+		ok bool
+	)
+
+	if *name != "" {
+		if f, err = psx.ParseFormat(*name); err != nil {
+			return err //nolint:wrapcheck
+		}
+	} else if f, ok = psx.FormatByExtension(out); !ok || fi.IsDir() {
+		// Each input in a directory could be a different format so there's
+		// no sensible default
+		return errNoFormat
+	}
+
+	if fi.IsDir() {
+		return convertTree(in, out, f, stdout)
+	}
+
+	return convertFile(in, out, f)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
+)
+
+func copyCard(t *testing.T, src, dst string) {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join("..", "..", "testdata", src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(dst, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func runCommand(args ...string) (int, string, string) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+	code := run(args, stdout, stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func readCard(t *testing.T, name string) (psx.Format, []string) {
+	t.Helper()
+
+	r, err := psx.OpenReader(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	names := make([]string, 0, len(r.File))
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+
+	return r.Format(), names
+}
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+
+	tables := map[string]struct {
+		args   []string
+		format psx.Format
+	}{
+		"extension": {
+			args:   []string{"out.gme"},
+			format: psx.FormatDexDrive,
+		},
+		"flag": {
+			args:   []string{"-f", "vmp", "out.bin"},
+			format: psx.FormatVMP,
+		},
+		"flag overrides extension": {
+			args:   []string{"-f", "raw", "out.gme"},
+			format: psx.FormatRaw,
+		},
+	}
+
+	for name, table := range tables {
+		name, table := name, table
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			in := filepath.Join(dir, "m1.mcd")
+			copyCard(t, "m1.mcd", in)
+
+			args := append([]string{"convert"}, table.args[:len(table.args)-1]...)
+			out := filepath.Join(dir, table.args[len(table.args)-1])
+
+			code, _, stderr := runCommand(append(args, in, out)...)
+			assert.Equal(t, 0, code, stderr)
+
+			_, want := readCard(t, in)
+			format, got := readCard(t, out)
+			assert.Equal(t, table.format, format)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestConvertNoFormat(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	copyCard(t, "m1.mcd", filepath.Join(dir, "in", "m1.mcd"))
+
+	tables := map[string]string{
+		"file":      filepath.Join(dir, "in", "m1.mcd"),
+		"directory": filepath.Join(dir, "in"),
+	}
+
+	for name, in := range tables {
+		name, in := name, in
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			code, _, stderr := runCommand("convert", in, filepath.Join(dir, "out."+name))
+			assert.Equal(t, 1, code)
+			assert.Contains(t, stderr, errNoFormat.Error())
+		})
+	}
+}
+
+func TestConvertTree(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	in, out := filepath.Join(dir, "in"), filepath.Join(dir, "out")
+
+	copyCard(t, "m1.mcd", filepath.Join(in, "m1.mcd"))
+	copyCard(t, "MemoryCard2-1.mcd", filepath.Join(in, "sub", "MemoryCard2-1.mcd"))
+
+	if err := os.WriteFile(filepath.Join(in, "README"), []byte("not a card"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runCommand("convert", "-f", "dexdrive", in, out)
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "2 converted, 1 skipped, 0 failed\n")
+
+	for _, name := range []string{"m1", filepath.Join("sub", "MemoryCard2-1")} {
+		_, want := readCard(t, filepath.Join(in, name+".mcd"))
+		format, got := readCard(t, filepath.Join(out, name+".gme"))
+		assert.Equal(t, psx.FormatDexDrive, format)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := os.Stat(filepath.Join(out, "README"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestConvertTreeConflict(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	in, out := filepath.Join(dir, "in"), filepath.Join(dir, "out")
+
+	copyCard(t, "m1.mcd", filepath.Join(in, "card.mcd"))
+	copyCard(t, "MemoryCard2-1.mcd", filepath.Join(in, "card.bin"))
+
+	code, stdout, _ := runCommand("convert", "-f", "vmp", in, out)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "FAIL "+filepath.Join(in, "card.mcd"))
+	assert.Contains(t, stdout, "1 converted, 0 skipped, 1 failed\n")
+
+	// The first input in walk order wins
+	_, want := readCard(t, filepath.Join(in, "card.bin"))
+	_, got := readCard(t, filepath.Join(out, "card.vmp"))
+	assert.Equal(t, want, got)
+}
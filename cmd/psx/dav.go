@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/bodgit/psx/davfs"
+	"golang.org/x/net/webdav"
+)
+
+func dav(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	addr := fs.String("addr", "localhost:8080", "listen on `address`")
+
+	if err := parseArgs(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	s := &http.Server{
+		Addr: *addr,
+		Handler: &webdav.Handler{
+			FileSystem: davfs.New(fs.Arg(0)),
+			LockSystem: webdav.NewMemLS(),
+		},
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	fmt.Fprintf(stdout, "serving %s on http://%s/\n", fs.Arg(0), *addr)
+
+	if err := s.ListenAndServe(); err != nil {
+		return fmt.Errorf("unable to serve: %w", err)
+	}
+
+	return nil
+}
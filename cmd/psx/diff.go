@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bodgit/psx"
+)
+
+var errDifferent = errors.New("memory cards differ")
+
+func formatBlocks(blocks []int) string {
+	s := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		s = append(s, strconv.Itoa(b))
+	}
+
+	return strings.Join(s, ",")
+}
+
+func formatSectors(sectors []uint32) string {
+	if len(sectors) == 0 {
+		return "none"
+	}
+
+	s := make([]string, 0, len(sectors))
+	for _, sector := range sectors {
+		s = append(s, fmt.Sprintf("%#x", sector))
+	}
+
+	return strings.Join(s, ",")
+}
+
+func printDiff(w io.Writer, d *psx.CardDiff) {
+	for _, fd := range d.Files {
+		switch fd.Change {
+		case psx.Added:
+			fmt.Fprintf(w, "+ %s (%d bytes)\n", fd.Name, fd.NewSize)
+		case psx.Removed:
+			fmt.Fprintf(w, "- %s (%d bytes)\n", fd.Name, fd.OldSize)
+		case psx.Modified:
+			changes := []string{}
+
+			if fd.OldSize != fd.NewSize {
+				changes = append(changes, fmt.Sprintf("size %d -> %d bytes", fd.OldSize, fd.NewSize))
+			}
+
+			if len(fd.Blocks) > 0 {
+				changes = append(changes, "blocks "+formatBlocks(fd.Blocks))
+			}
+
+			if fd.Title {
+				changes = append(changes, "title")
+			}
+
+			if fd.Icon {
+				changes = append(changes, "icon")
+			}
+
+			fmt.Fprintf(w, "M %s: %s\n", fd.Name, strings.Join(changes, ", "))
+		}
+	}
+
+	if d.BrokenSectors() {
+		fmt.Fprintf(w, "broken sectors: %s -> %s\n", formatSectors(d.OldBrokenSectors), formatSectors(d.NewBrokenSectors))
+	}
+
+	if d.Header {
+		fmt.Fprintln(w, "header frames differ")
+	}
+}
+
+func diff(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	quiet := fs.Bool("q", false, "only report whether the memory cards differ")
+
+	if err := parseArgs(fs, args, 2, 2); err != nil { //nolint:gomnd
+		return err
+	}
+
+	a, err := psx.OpenReader(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	defer a.Close()
+
+	b, err := psx.OpenReader(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(1), err)
+	}
+	defer b.Close()
+
+	d, err := psx.Diff(&a.Reader, &b.Reader)
+	if err != nil {
+		return err
+	}
+
+	if d.Equal() {
+		return nil
+	}
+
+	if !*quiet {
+		printDiff(stdout, d)
+	}
+
+	return errDifferent
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	m1 := filepath.Join("..", "..", "testdata", "m1.mcd")
+	dirty := filepath.Join("..", "..", "testdata", "dirty.mcr")
+
+	tables := map[string]struct {
+		args   []string
+		code   int
+		stdout string
+	}{
+		"same": {
+			args: []string{m1, m1},
+		},
+		"different": {
+			args: []string{m1, dirty},
+			code: 1,
+			stdout: "- BASLUS-01040VAG1 (24704 bytes)\n" +
+				"broken sectors: none -> 0xc,0x1f0\n" +
+				"header frames differ\n",
+		},
+		"quiet": {
+			args: []string{"-q", m1, dirty},
+			code: 1,
+		},
+		"missing": {
+			args: []string{m1, filepath.Join("..", "..", "testdata", "missing.mcd")},
+			code: 1,
+		},
+		"usage": {
+			args: []string{m1},
+			code: 2,
+		},
+	}
+
+	for name, table := range tables {
+		name, table := name, table
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			code, stdout, _ := runCommand(append([]string{"diff"}, table.args...)...)
+			assert.Equal(t, table.code, code)
+			assert.Equal(t, table.stdout, stdout)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Command psx manipulates Sony PlayStation 1 memory card images.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+type command struct {
+	usage string
+	run   func(*flag.FlagSet, []string, io.Writer) error
+}
+
+//nolint:gochecknoglobals
+var commands = map[string]command{
+	"catalog": {
+		usage: "catalog [-games file] directory card ...",
+		run:   catalog,
+	},
+	"check": {
+		usage: "check [-q] [-o path | -w] card ...",
+		run:   check,
+	},
+	"convert": {
+		usage: "convert [-f format] input output",
+		run:   convert,
+	},
+	"dav": {
+		usage: "dav [-addr address] card|directory",
+		run:   dav,
+	},
+	"diff": {
+		usage: "diff [-q] card1 card2",
+		run:   diff,
+	},
+	"gather": {
+		usage: "gather [-layout layout] [-policy policy] [-f format] directory output",
+		run:   gather,
+	},
+	"serve": {
+		usage: "serve [-addr address] directory",
+		run:   serve,
+	},
+	"split": {
+		usage: "split [-layout layout] [-group serials]... [-overwrite] card directory",
+		run:   split,
+	},
+}
+
+var (
+	errUsage = errors.New("usage")
+	errParse = errors.New("parse")
+)
+
+func parseArgs(fs *flag.FlagSet, args []string, minArgs, maxArgs int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err //nolint:wrapcheck
+		}
+
+		// The flag package has already reported the error
+		return errParse
+	}
+
+	if fs.NArg() < minArgs || (maxArgs >= 0 && fs.NArg() > maxArgs) {
+		return errUsage
+	}
+
+	return nil
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: psx <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintln(w, "  psx", commands[name].usage)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+
+		return 2 //nolint:gomnd
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage(stderr)
+
+		return 2 //nolint:gomnd
+	}
+
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: psx", cmd.usage)
+		fs.PrintDefaults()
+	}
+
+	switch err := cmd.run(fs, args[1:], stdout); {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errParse):
+		return 2 //nolint:gomnd
+	case errors.Is(err, errUsage):
+		fs.Usage()
+
+		return 2 //nolint:gomnd
+	default:
+		fmt.Fprintln(stderr, "psx:", err)
+
+		return 1
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
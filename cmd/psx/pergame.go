@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bodgit/psx"
+)
+
+//nolint:gochecknoglobals
+var (
+	layouts = map[string]psx.Layout{
+		"memcardpro":  psx.LayoutMemCardPro,
+		"duckstation": psx.LayoutDuckStation,
+	}
+	policies = map[string]psx.ConflictPolicy{
+		"first":   psx.KeepFirst,
+		"newest":  psx.KeepNewest,
+		"largest": psx.KeepLargest,
+		"both":    psx.KeepBoth,
+	}
+)
+
+var errOutsideRoot = errors.New("path outside of output directory")
+
+// notExists returns an error if the named file already exists.
+func notExists(name string) error {
+	if _, err := os.Lstat(name); err == nil {
+		return fmt.Errorf("%s: %w (use -overwrite to replace it)", name, os.ErrExist)
+	}
+
+	return nil
+}
+
+// within joins the slash-separated path name onto root, checking the result
+// doesn't escape root.
+func within(root, name string) (string, error) {
+	p := filepath.Join(root, filepath.FromSlash(name))
+
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return "", fmt.Errorf("unable to compute relative path: %w", err)
+	}
+
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", fmt.Errorf("%w: %s", errOutsideRoot, p)
+	}
+
+	return p, nil
+}
+
+type groupsFlag [][]string
+
+func (g *groupsFlag) String() string {
+	return fmt.Sprint(*g)
+}
+
+func (g *groupsFlag) Set(s string) error {
+	*g = append(*g, strings.Split(s, ","))
+
+	return nil
+}
+
+func parseLayout(s string) (psx.Layout, error) {
+	l, ok := layouts[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown layout: %s", s) //nolint:goerr113
+	}
+
+	return l, nil
+}
+
+func createCard(name string, format psx.Format) (*psx.Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0o777); err != nil { //nolint:gosec
+		return nil, fmt.Errorf("unable to create directory: %w", err)
+	}
+
+	return psx.CreateFile(name, format) //nolint:wrapcheck
+}
+
+func split(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	layout := fs.String("layout", "memcardpro", "directory `layout`: memcardpro or duckstation")
+	overwrite := fs.Bool("overwrite", false, "replace any existing per-game memory cards")
+
+	var groups groupsFlag
+
+	fs.Var(&groups, "group", "comma-separated `serials` of a multi-disc game to keep together, may be repeated")
+
+	if err := parseArgs(fs, args, 2, 2); err != nil { //nolint:gomnd
+		return err
+	}
+
+	l, err := parseLayout(*layout)
+	if err != nil {
+		return err
+	}
+
+	rc, err := psx.OpenReader(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	defer rc.Close()
+
+	serials, err := psx.Split(&rc.Reader, psx.GroupSerials(groups...), func(serial string, n int) (*psx.Writer, error) {
+		name, err := within(fs.Arg(1), l.Path(serial, n))
+		if err != nil {
+			return nil, err
+		}
+
+		if !*overwrite {
+			if err := notExists(name); err != nil {
+				return nil, err
+			}
+		}
+
+		fmt.Fprintln(stdout, name)
+
+		return createCard(name, psx.FormatRaw)
+	})
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	fmt.Fprintf(stdout, "%d files split across %d games\n", len(rc.File), len(serials))
+
+	return nil
+}
+
+func numbered(name string, n int) string {
+	if n == 0 {
+		return name
+	}
+
+	ext := filepath.Ext(name)
+
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), n+1, ext)
+}
+
+func gather(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	layout := fs.String("layout", "memcardpro", "directory `layout`: memcardpro or duckstation")
+	policy := fs.String("policy", "first", "conflict `policy`: first, newest, largest or both")
+	name := fs.String("f", "", "output `format`: raw, dexdrive or vmp (default from output extension)")
+
+	if err := parseArgs(fs, args, 2, 2); err != nil { //nolint:gomnd
+		return err
+	}
+
+	l, err := parseLayout(*layout)
+	if err != nil {
+		return err
+	}
+
+	p, ok := policies[*policy]
+	if !ok {
+		return fmt.Errorf("unknown policy: %s", *policy) //nolint:goerr113
+	}
+
+	out := fs.Arg(1)
+
+	var f psx.Format
+
+	if *name != "" {
+		if f, err = psx.ParseFormat(*name); err != nil {
+			return err //nolint:wrapcheck
+		}
+	} else if f, ok = psx.FormatByExtension(out); !ok {
+		return errNoFormat
+	}
+
+	n, err := psx.Gather(os.DirFS(fs.Arg(0)), l, func(i int) (*psx.Writer, error) {
+		name := numbered(out, i)
+
+		fmt.Fprintln(stdout, name)
+
+		return createCard(name, f)
+	}, p)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	fmt.Fprintf(stdout, "gathered onto %d memory cards\n", n)
+
+	return nil
+}
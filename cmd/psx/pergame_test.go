@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithin(t *testing.T) {
+	t.Parallel()
+
+	root := filepath.Join("out", "cards")
+
+	tables := []struct {
+		name string
+		path string
+		err  error
+	}{
+		{"SLUS-00594/SLUS-00594-1.mcd", filepath.Join(root, "SLUS-00594", "SLUS-00594-1.mcd"), nil},
+		{"SLUS-00594_1.mcd", filepath.Join(root, "SLUS-00594_1.mcd"), nil},
+		{"../SLUS-00594_1.mcd", "", errOutsideRoot},
+		{"X/../../../X-1.mcd", "", errOutsideRoot},
+		{"..", "", errOutsideRoot},
+	}
+
+	for _, table := range tables {
+		table := table
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			p, err := within(root, table.name)
+			assert.Equal(t, table.path, p)
+			assert.True(t, errors.Is(err, table.err))
+		})
+	}
+}
+
+func TestSplitExisting(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	in, out := filepath.Join(dir, "m1.mcd"), filepath.Join(dir, "out")
+
+	copyCard(t, "m1.mcd", in)
+
+	code, _, stderr := runCommand("split", in, out)
+	assert.Equal(t, 0, code, stderr)
+
+	name := filepath.Join(out, "SLUS-01040", "SLUS-01040-1.mcd")
+
+	before, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Make the existing memory card different from what split would write
+	copyCard(t, "blank.mcd", name)
+
+	code, _, stderr = runCommand("split", in, out)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, os.ErrExist.Error())
+
+	_, names := readCard(t, name)
+	assert.Empty(t, names)
+
+	code, _, stderr = runCommand("split", "-overwrite", in, out)
+	assert.Equal(t, 0, code, stderr)
+
+	after, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, before, after)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bodgit/psx/server"
+)
+
+const readHeaderTimeout = 10 * time.Second
+
+func serve(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	addr := fs.String("addr", "localhost:8080", "listen on `address`")
+
+	if err := parseArgs(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	s := &http.Server{
+		Addr:              *addr,
+		Handler:           server.New(fs.Arg(0)),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	fmt.Fprintf(stdout, "serving %s on http://%s/cards\n", fs.Arg(0), *addr)
+
+	if err := s.ListenAndServe(); err != nil {
+		return fmt.Errorf("unable to serve: %w", err)
+	}
+
+	return nil
+}
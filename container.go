@@ -0,0 +1,165 @@
+package psx
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// A ContainerReader serves the pages of a multi-card image, where several
+// memory cards are stored back to back, such as those used by "mega" memory
+// cards with multiple slots. An image containing a single memory card in any
+// supported format is treated as a container with one page.
+type ContainerReader struct {
+	Page []*Reader
+}
+
+// pages returns the Format and each memory card found within the image b.
+func pages(b []byte) (Format, [][]byte, error) {
+	f, offset := FormatRaw, 0
+
+	// Memory cards stored back to back with nothing else are just a series
+	// of raw memory card images. This is checked first so that a corrupt
+	// page isn't skipped over when searching for a memory card.
+	if len(b) == 0 || len(b)%cardSize != 0 {
+		found, o, ok, err := detectFormat(bytes.NewReader(b), int64(len(b)), make([]byte, frameSize))
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if ok {
+			f, offset = found, int(o)
+		}
+	}
+
+	n := (len(b) - offset) / cardSize
+
+	switch {
+	case n == 0:
+		return 0, nil, ErrInvalidLength
+	case f != FormatSRM && (len(b)-offset)%cardSize != 0:
+		return 0, nil, ErrTrailingBytes
+	}
+
+	p := make([][]byte, n)
+	for i := range p {
+		p[i] = b[offset+i*cardSize : offset+(i+1)*cardSize]
+	}
+
+	return f, p, nil
+}
+
+func (cr *ContainerReader) init(r io.Reader) error {
+	b, err := io.ReadAll(io.LimitReader(r, maxImageSize+1))
+	if err != nil {
+		return fmt.Errorf("unable to read memory card: %w", err)
+	}
+
+	if len(b) > maxImageSize {
+		return ErrTrailingBytes
+	}
+
+	f, p, err := pages(b)
+	if err != nil {
+		return err
+	}
+
+	cr.Page = make([]*Reader, len(p))
+
+	for i := range p {
+		cr.Page[i] = new(Reader)
+
+		if err := cr.Page[i].load(f, bytes.NewReader(p[i])); err != nil {
+			return fmt.Errorf("page %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// NewContainerReader returns a new ContainerReader reading from r.
+func NewContainerReader(r io.Reader) (*ContainerReader, error) {
+	cr := new(ContainerReader)
+	if err := cr.init(r); err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+// OpenContainerReader will open the multi-card image specified by name and
+// return a ContainerReader.
+func OpenContainerReader(name string) (*ContainerReader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open: %w", err)
+	}
+	defer f.Close()
+
+	cr := new(ContainerReader)
+	if err := cr.init(f); err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+type page struct {
+	w   *Writer
+	buf *bytes.Buffer
+}
+
+// A ContainerWriter is used for creating a new multi-card image, with each
+// page written by its own Writer.
+type ContainerWriter struct {
+	mu    sync.Mutex
+	w     io.Writer
+	pages []page
+}
+
+// NewContainerWriter returns a ContainerWriter that will write a new
+// multi-card image to w.
+func NewContainerWriter(w io.Writer) *ContainerWriter {
+	return &ContainerWriter{w: w}
+}
+
+// Create adds a new page to the end of the multi-card image and returns a
+// Writer for adding files to it.
+func (cw *ContainerWriter) Create() (*Writer, error) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	buf := new(bytes.Buffer)
+
+	w, err := NewWriter(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	cw.pages = append(cw.pages, page{w, buf})
+
+	return w, nil
+}
+
+// Close writes out each page to the underlying io.Writer. Any page whose
+// Writer hasn't been closed yet is closed first.
+func (cw *ContainerWriter) Close() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	for i, p := range cw.pages {
+		if p.buf.Len() == 0 {
+			if err := p.w.Close(); err != nil {
+				return fmt.Errorf("page %d: %w", i+1, err)
+			}
+		}
+
+		if _, err := p.buf.WriteTo(cw.w); err != nil {
+			return fmt.Errorf("unable to write memory card: %w", err)
+		}
+	}
+
+	return nil
+}
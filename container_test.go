@@ -0,0 +1,144 @@
+package psx_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerReader(t *testing.T) {
+	t.Parallel()
+
+	m1, err := os.ReadFile(filepath.Join("testdata", "m1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blank, err := os.ReadFile(filepath.Join("testdata", "blank.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := append([]byte{}, m1...)
+	corrupt[0x7f] ^= 0xff
+
+	tables := []struct {
+		name   string
+		b      []byte
+		format psx.Format
+		files  []int
+		err    error
+	}{
+		{
+			name:   "single",
+			b:      m1,
+			format: psx.FormatRaw,
+			files:  []int{10},
+		},
+		{
+			name:   "mega",
+			b:      bytes.Join([][]byte{m1, blank, blank, m1}, nil),
+			format: psx.FormatRaw,
+			files:  []int{10, 0, 0, 10},
+		},
+		{
+			name:   "srm",
+			b:      bytes.Join([][]byte{make([]byte, 0x100), blank, m1, []byte("RetroArch")}, nil),
+			format: psx.FormatSRM,
+			files:  []int{0, 10},
+		},
+		{
+			name: "short",
+			b:    m1[:0x1000],
+			err:  psx.ErrInvalidLength,
+		},
+		{
+			name: "corrupt page",
+			b:    bytes.Join([][]byte{m1, make([]byte, len(blank))}, nil),
+			err:  psx.ErrBadHeaderSignature,
+		},
+		{
+			name: "corrupt first page",
+			b:    bytes.Join([][]byte{corrupt, m1}, nil),
+			err:  psx.ErrBadHeaderChecksum,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			cr, err := psx.NewContainerReader(bytes.NewReader(table.b))
+			if table.err != nil {
+				assert.True(t, errors.Is(err, table.err))
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			files := make([]int, 0, len(cr.Page))
+			for _, r := range cr.Page {
+				assert.Equal(t, table.format, r.Format())
+				files = append(files, len(r.File))
+			}
+
+			assert.Equal(t, table.files, files)
+		})
+	}
+}
+
+func TestContainerWriter(t *testing.T) {
+	t.Parallel()
+
+	m1, err := os.ReadFile(filepath.Join("testdata", "m1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mc2, err := os.ReadFile(filepath.Join("testdata", "MemoryCard2-1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	cw := psx.NewContainerWriter(buf)
+
+	n, err := psx.Merge(func(int) (*psx.Writer, error) {
+		return cw.Create()
+	}, psx.MergeOptions{}, openReaders(t, m1, mc2)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An empty page left for the Writer to close
+	if _, err := cw.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 3*len(m1), buf.Len())
+
+	cr, err := psx.NewContainerReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, cr.Page, 3) {
+		assert.Len(t, cr.Page[0].File, 11)
+		assert.Len(t, cr.Page[1].File, 9)
+		assert.Empty(t, cr.Page[2].File)
+	}
+}
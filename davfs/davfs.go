@@ -0,0 +1,485 @@
+// Package davfs provides a webdav.FileSystem for managing the files on Sony
+// PlayStation 1 memory card images with a WebDAV client, such as a desktop
+// file manager.
+//
+// The root of the file system is either a single memory card image or a
+// directory. Within a directory, subdirectories are shown as they are, each
+// memory card image is shown as a directory, and anything else is hidden. The
+// files on a memory card are named as they are on the memory card and are
+// read and written in the MCS format, which is the directory frame of the file
+// followed by its blocks.
+//
+// Writing a file to a memory card adds it, replacing any file with the same
+// name, and deleting a file removes it. The file is renamed to the name
+// written to, optionally with an ".mcs" extension, which must share the
+// country and product code of the name embedded in the file. Renaming a file
+// only changes its identifier, the rest of the name must stay the same, and
+// renaming it onto another memory card moves it. Anything written that isn't
+// a valid file is rejected, except for the empty files some clients create
+// first and AppleDouble "._" files from macOS, which are discarded. Each
+// memory card image is replaced atomically.
+package davfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bodgit/psx"
+	"github.com/bodgit/psx/internal/cardfile"
+	"golang.org/x/net/webdav"
+)
+
+const mcsExt = ".mcs"
+
+var (
+	errIsDirectory  = errors.New("is a directory")
+	errNotDirectory = errors.New("not a directory")
+	errTooLarge     = errors.New("file too large")
+)
+
+// A FileSystem serves a memory card image or a directory of them.
+type FileSystem struct {
+	mu   sync.RWMutex
+	root string
+}
+
+var _ webdav.FileSystem = new(FileSystem)
+
+// New returns a FileSystem rooted at root, which is either a memory card
+// image or a directory.
+func New(root string) *FileSystem {
+	return &FileSystem{root: root}
+}
+
+// A location is what a name within the FileSystem refers to.
+type location struct {
+	name string // OS path of the directory or memory card image
+	card bool
+	save string
+}
+
+func pathError(op, name string, err error) error {
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+func isCard(name string) bool {
+	f, err := os.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	ok, err := psx.DetectMemoryCard(f, fi.Size())
+
+	return err == nil && ok
+}
+
+// resolve works out what name refers to, without checking a file on a memory
+// card exists.
+func (fsys *FileSystem) resolve(op, name string) (*location, error) {
+	fi, err := os.Stat(fsys.root)
+	if err != nil {
+		return nil, pathError(op, name, fs.ErrNotExist)
+	}
+
+	loc := &location{name: fsys.root, card: !fi.IsDir()}
+
+	clean := strings.Trim(path.Clean("/"+name), "/")
+	if clean == "" {
+		return loc, nil
+	}
+
+	for _, part := range strings.Split(clean, "/") {
+		switch {
+		case loc.save != "":
+			return nil, pathError(op, name, fs.ErrNotExist)
+		case loc.card:
+			loc.save = part
+		default:
+			loc.name = filepath.Join(loc.name, part)
+
+			fi, err := os.Stat(loc.name)
+			if err != nil || !fi.IsDir() && !isCard(loc.name) {
+				return nil, pathError(op, name, fs.ErrNotExist)
+			}
+
+			loc.card = !fi.IsDir()
+		}
+	}
+
+	return loc, nil
+}
+
+// A cardInfo describes a memory card image as a directory.
+type cardInfo struct {
+	fs.FileInfo
+}
+
+func (cardInfo) Size() int64       { return 0 }
+func (cardInfo) IsDir() bool       { return true }
+func (cardInfo) Mode() fs.FileMode { return fs.ModeDir | 0o755 } //nolint:gomnd
+
+func (fsys *FileSystem) stat(op, name string, loc *location) (fs.FileInfo, error) {
+	if loc.save == "" {
+		fi, err := os.Stat(loc.name)
+		if err != nil {
+			return nil, pathError(op, name, fs.ErrNotExist)
+		}
+
+		if loc.card {
+			return cardInfo{fi}, nil
+		}
+
+		return fi, nil
+	}
+
+	rc, err := psx.OpenReader(loc.name)
+	if err != nil {
+		return nil, pathError(op, name, err)
+	}
+	defer rc.Close()
+
+	fi, err := fs.Stat(&rc.Reader, loc.save)
+	if err != nil || fi.IsDir() {
+		return nil, pathError(op, name, fs.ErrNotExist)
+	}
+
+	return fi, nil
+}
+
+// Stat returns the fs.FileInfo for name.
+func (fsys *FileSystem) Stat(_ context.Context, name string) (os.FileInfo, error) {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+
+	loc, err := fsys.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fsys.stat("stat", name, loc)
+}
+
+// Mkdir always fails as directories can't be created.
+func (fsys *FileSystem) Mkdir(_ context.Context, name string, _ os.FileMode) error {
+	return pathError("mkdir", name, fs.ErrPermission)
+}
+
+func (fsys *FileSystem) readDir(loc *location) ([]fs.FileInfo, error) {
+	if loc.card {
+		rc, err := psx.OpenReader(loc.name)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+		defer rc.Close()
+
+		entries := make([]fs.FileInfo, 0, len(rc.File))
+		for _, f := range rc.File {
+			entries = append(entries, f.FileInfo())
+		}
+
+		return entries, nil
+	}
+
+	des, err := os.ReadDir(loc.name)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	entries := make([]fs.FileInfo, 0, len(des))
+
+	for _, de := range des {
+		fi, err := os.Stat(filepath.Join(loc.name, de.Name()))
+
+		switch {
+		case err != nil:
+			continue
+		case fi.IsDir():
+			entries = append(entries, fi)
+		case isCard(filepath.Join(loc.name, de.Name())):
+			entries = append(entries, cardInfo{fi})
+		}
+	}
+
+	return entries, nil
+}
+
+// OpenFile opens name. Directories and files on a memory card are opened for
+// reading unless flag includes os.O_WRONLY, os.O_RDWR, os.O_CREATE or
+// os.O_TRUNC, in which case a file is written to the memory card when it's
+// closed. The perm argument is ignored.
+func (fsys *FileSystem) OpenFile(_ context.Context, name string, flag int, _ os.FileMode) (webdav.File, error) {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+
+	loc, err := fsys.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) != 0 {
+		if loc.save == "" {
+			return nil, pathError("open", name, fs.ErrPermission)
+		}
+
+		return &upload{fsys: fsys, loc: loc, name: name, modTime: time.Now()}, nil
+	}
+
+	fi, err := fsys.stat("open", name, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	if loc.save == "" {
+		entries, err := fsys.readDir(loc)
+		if err != nil {
+			return nil, pathError("open", name, err)
+		}
+
+		return &dir{fi: fi, entries: entries}, nil
+	}
+
+	rc, err := psx.OpenReader(loc.name)
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+	defer rc.Close()
+
+	b, err := cardfile.Read(&rc.Reader, loc.save)
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+
+	return &file{Reader: bytes.NewReader(b), fi: fi}, nil
+}
+
+// RemoveAll removes the file name from its memory card. Directories and
+// memory card images can't be removed.
+func (fsys *FileSystem) RemoveAll(_ context.Context, name string) error {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+
+	loc, err := fsys.resolve("remove", name)
+	if err != nil {
+		return err
+	}
+
+	if loc.save == "" {
+		return pathError("remove", name, fs.ErrPermission)
+	}
+
+	if _, err := fsys.stat("remove", name, loc); err != nil {
+		return err
+	}
+
+	if err := cardfile.Remove(loc.name, loc.save); err != nil {
+		return pathError("remove", name, err)
+	}
+
+	return nil
+}
+
+// Rename renames the file oldName to newName. Only the identifier can be
+// changed. If newName is on another memory card the file is added to it
+// before being removed from the original memory card, so it's never lost.
+func (fsys *FileSystem) Rename(_ context.Context, oldName, newName string) error {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+
+	from, err := fsys.resolve("rename", oldName)
+	if err != nil {
+		return err
+	}
+
+	to, err := fsys.resolve("rename", newName)
+	if err != nil {
+		return err
+	}
+
+	if from.save == "" || to.save == "" {
+		return pathError("rename", oldName, fs.ErrPermission)
+	}
+
+	if _, err := fsys.stat("rename", oldName, from); err != nil {
+		return err
+	}
+
+	to.save = trimExt(to.save)
+
+	if from.name == to.name {
+		if from.save == to.save {
+			return nil
+		}
+
+		if err := cardfile.Move(from.name, from.save, to.save); err != nil {
+			return pathError("rename", oldName, err)
+		}
+
+		return nil
+	}
+
+	rc, err := psx.OpenReader(from.name)
+	if err != nil {
+		return pathError("rename", oldName, err)
+	}
+
+	b, err := cardfile.Read(&rc.Reader, from.save)
+	rc.Close()
+
+	if err != nil {
+		return pathError("rename", oldName, err)
+	}
+
+	if to.save != from.save {
+		if err := psx.RenameSave(b, to.save); err != nil {
+			return pathError("rename", newName, err)
+		}
+	}
+
+	if err := cardfile.Add(to.name, b); err != nil {
+		return pathError("rename", newName, err)
+	}
+
+	if err := cardfile.Remove(from.name, from.save); err != nil {
+		return pathError("rename", oldName, err)
+	}
+
+	return nil
+}
+
+// trimExt removes any ".mcs" extension from save.
+func trimExt(save string) string {
+	if strings.EqualFold(path.Ext(save), mcsExt) {
+		return save[:len(save)-len(mcsExt)]
+	}
+
+	return save
+}
+
+// write renames the file in b to save and then writes it to the memory card
+// image in the named file. An empty file is ignored, as some clients create
+// one before writing to it.
+func (fsys *FileSystem) write(name, save string, b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+
+	if err := psx.ValidateSave(b); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if err := psx.RenameSave(b, trimExt(save)); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+
+	return cardfile.Put(name, b) //nolint:wrapcheck
+}
+
+// A dir is a directory or memory card opened for reading.
+type dir struct {
+	fi      fs.FileInfo
+	entries []fs.FileInfo
+}
+
+func (d *dir) Close() error                   { return nil }
+func (d *dir) Read([]byte) (int, error)       { return 0, errIsDirectory }
+func (d *dir) Seek(int64, int) (int64, error) { return 0, errIsDirectory }
+func (d *dir) Write([]byte) (int, error)      { return 0, errIsDirectory }
+func (d *dir) Stat() (fs.FileInfo, error)     { return d.fi, nil }
+func (d *dir) Readdir(count int) ([]fs.FileInfo, error) {
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+
+		return entries, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > len(d.entries) {
+		count = len(d.entries)
+	}
+
+	entries := d.entries[:count]
+	d.entries = d.entries[count:]
+
+	return entries, nil
+}
+
+// A file is a file on a memory card opened for reading.
+type file struct {
+	*bytes.Reader
+	fi fs.FileInfo
+}
+
+func (f *file) Close() error                       { return nil }
+func (f *file) Write([]byte) (int, error)          { return 0, fs.ErrPermission }
+func (f *file) Stat() (fs.FileInfo, error)         { return f.fi, nil }
+func (f *file) Readdir(int) ([]fs.FileInfo, error) { return nil, errNotDirectory }
+
+// An upload is a file being written to a memory card, which happens when it's
+// closed.
+type upload struct {
+	fsys    *FileSystem
+	loc     *location
+	name    string
+	buf     bytes.Buffer
+	modTime time.Time
+}
+
+func (u *upload) Read([]byte) (int, error)           { return 0, fs.ErrPermission }
+func (u *upload) Seek(int64, int) (int64, error)     { return 0, fs.ErrPermission }
+func (u *upload) Readdir(int) ([]fs.FileInfo, error) { return nil, errNotDirectory }
+func (u *upload) Stat() (fs.FileInfo, error)         { return uploadInfo{u}, nil }
+
+func (u *upload) Write(p []byte) (int, error) {
+	if u.buf.Len()+len(p) > psx.MaxSaveSize {
+		return 0, pathError("write", u.name, errTooLarge)
+	}
+
+	return u.buf.Write(p) //nolint:wrapcheck
+}
+
+func (u *upload) Close() error {
+	// AppleDouble files holding the extended attributes of a file written
+	// by macOS have nowhere to go
+	if strings.HasPrefix(u.loc.save, "._") {
+		return nil
+	}
+
+	if err := u.fsys.write(u.loc.name, u.loc.save, u.buf.Bytes()); err != nil {
+		return pathError("close", u.name, err)
+	}
+
+	return nil
+}
+
+type uploadInfo struct {
+	u *upload
+}
+
+func (fi uploadInfo) Name() string       { return fi.u.loc.save }
+func (fi uploadInfo) Size() int64        { return int64(fi.u.buf.Len()) }
+func (fi uploadInfo) Mode() fs.FileMode  { return 0o644 } //nolint:gomnd
+func (fi uploadInfo) ModTime() time.Time { return fi.u.modTime }
+func (fi uploadInfo) IsDir() bool        { return false }
+func (fi uploadInfo) Sys() interface{}   { return nil }
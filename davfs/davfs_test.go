@@ -0,0 +1,264 @@
+package davfs_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/bodgit/psx/davfs"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+func copyCard(t *testing.T, src, dst string) {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join("..", "testdata", src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(dst, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func saves(t *testing.T, name string) []string {
+	t.Helper()
+
+	rc, err := psx.OpenReader(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	names := make([]string, 0, len(rc.File))
+	for _, f := range rc.File {
+		names = append(names, f.Name)
+	}
+
+	return names
+}
+
+func do(t *testing.T, h http.Handler, method, target string, body []byte, hdr ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+
+	req := httptest.NewRequest(method, target, r)
+	for i := 0; i+1 < len(hdr); i += 2 {
+		req.Header.Set(hdr[i], hdr[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	return w
+}
+
+//nolint:funlen
+func TestFileSystem(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	copyCard(t, "m1.mcd", filepath.Join(dir, "m1.mcd"))
+	copyCard(t, "MemoryCard2-1.mcd", filepath.Join(dir, "sub", "full.mcd"))
+	copyCard(t, "blank.mcd", filepath.Join(dir, "blank.mcd"))
+
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &webdav.Handler{
+		FileSystem: davfs.New(dir),
+		LockSystem: webdav.NewMemLS(),
+	}
+
+	w := do(t, h, "PROPFIND", "/", nil, "Depth", "1")
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	assert.Contains(t, w.Body.String(), "<D:href>/m1.mcd/</D:href>")
+	assert.Contains(t, w.Body.String(), "<D:href>/sub/</D:href>")
+	assert.NotContains(t, w.Body.String(), "notes.txt")
+
+	w = do(t, h, "PROPFIND", "/m1.mcd/", nil, "Depth", "1")
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	assert.Contains(t, w.Body.String(), "<D:href>/m1.mcd/BASLUS-00603-DASH00</D:href>")
+
+	w = do(t, h, http.MethodGet, "/m1.mcd/BASLUS-00603-DASH00", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	save := w.Body.Bytes()
+	assert.Len(t, save, 8320)
+
+	w = do(t, h, http.MethodPut, "/blank.mcd/BASLUS-00603-DASH00.mcs", save)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// A name with the same country and product code renames it
+	w = do(t, h, http.MethodPut, "/blank.mcd/BASLUS-00603-OTHER.mcs", save)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, []string{"BASLUS-00603-DASH00", "BASLUS-00603-OTHER"}, saves(t, filepath.Join(dir, "blank.mcd")))
+
+	// AppleDouble files are discarded
+	w = do(t, h, http.MethodPut, "/blank.mcd/._BASLUS-00603-OTHER.mcs", save)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// Anything that isn't a valid file, or a name that changes more than
+	// the identifier, is rejected
+	corrupt := append([]byte{}, save...)
+	corrupt[0x20] ^= 0xff
+
+	for name, b := range map[string][]byte{
+		"notes.txt":             []byte("notes"),
+		"BASLUS-00603-BAD.mcs":  corrupt,
+		"save.mcs":              save,
+		"BESLES-00024OTHER.mcs": save,
+	} {
+		w = do(t, h, http.MethodPut, "/blank.mcd/"+name, b)
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code, name)
+	}
+
+	assert.Equal(t, []string{"BASLUS-00603-DASH00", "BASLUS-00603-OTHER"}, saves(t, filepath.Join(dir, "blank.mcd")))
+
+	w = do(t, h, "MOVE", "/blank.mcd/BASLUS-00603-OTHER", nil, "Destination", "/blank.mcd/BASLUS-00603-NEW.mcs")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, []string{"BASLUS-00603-DASH00", "BASLUS-00603-NEW"}, saves(t, filepath.Join(dir, "blank.mcd")))
+
+	// Renaming a file to its own name changes nothing
+	w = do(t, h, "MOVE", "/blank.mcd/BASLUS-00603-NEW", nil, "Destination", "/blank.mcd/BASLUS-00603-NEW.mcs")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, []string{"BASLUS-00603-DASH00", "BASLUS-00603-NEW"}, saves(t, filepath.Join(dir, "blank.mcd")))
+
+	// Only the identifier can change
+	w = do(t, h, "MOVE", "/blank.mcd/BASLUS-00603-NEW", nil, "Destination", "/blank.mcd/BESLES-00024NEW")
+	assert.NotEqual(t, http.StatusCreated, w.Code)
+
+	// Moving onto a full memory card leaves the file where it was
+	w = do(t, h, "MOVE", "/m1.mcd/BISLPS-00817POM1", nil, "Destination", "/sub/full.mcd/BISLPS-00817POM1")
+	assert.NotEqual(t, http.StatusCreated, w.Code)
+	assert.Len(t, saves(t, filepath.Join(dir, "m1.mcd")), 10)
+
+	w = do(t, h, "MOVE", "/m1.mcd/BISLPS-00817POM1", nil, "Destination", "/blank.mcd/BISLPS-00817POM2")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Len(t, saves(t, filepath.Join(dir, "m1.mcd")), 9)
+	assert.Contains(t, saves(t, filepath.Join(dir, "blank.mcd")), "BISLPS-00817POM2")
+
+	w = do(t, h, http.MethodDelete, "/blank.mcd/BASLUS-00603-DASH00", nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, []string{"BASLUS-00603-NEW", "BISLPS-00817POM2"}, saves(t, filepath.Join(dir, "blank.mcd")))
+
+	tables := []struct {
+		method, target string
+		code           int
+	}{
+		{http.MethodGet, "/missing.mcd/", http.StatusNotFound},
+		{http.MethodGet, "/notes.txt", http.StatusNotFound},
+		{http.MethodGet, "/m1.mcd/missing", http.StatusNotFound},
+		{http.MethodDelete, "/m1.mcd/missing", http.StatusNotFound},
+		{http.MethodDelete, "/m1.mcd", http.StatusMethodNotAllowed},
+		{"MKCOL", "/m1.mcd/dir", http.StatusMethodNotAllowed},
+	}
+
+	for _, table := range tables {
+		w := do(t, h, table.method, table.target, nil)
+		assert.Equal(t, table.code, w.Code, table.method+" "+table.target)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No temporary files are left behind
+	assert.Len(t, entries, 4)
+}
+
+func TestFileSystemCard(t *testing.T) {
+	t.Parallel()
+
+	name := filepath.Join(t.TempDir(), "m1.mcd")
+	copyCard(t, "m1.mcd", name)
+
+	ctx := context.Background()
+	fsys := davfs.New(name)
+
+	fi, err := fsys.Stat(ctx, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, fi.IsDir())
+
+	f, err := fsys.OpenFile(ctx, "/", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := f.Readdir(-1)
+	f.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, entries, 10)
+
+	if err := fsys.RemoveAll(ctx, "/BASCUS-94236TOMBA-00"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = fsys.Stat(ctx, "/BASCUS-94236TOMBA-00")
+	assert.True(t, os.IsNotExist(err))
+
+	err = fsys.Rename(ctx, "/BISLPS-00093", "/"+strings.Repeat("X", 12))
+	assert.Error(t, err)
+
+	assert.Len(t, saves(t, name), 9)
+}
+
+// Clients such as the macOS Finder and Windows Explorer create an empty file
+// before writing the real one.
+func TestFileSystemEmptyPut(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	copyCard(t, "m1.mcd", filepath.Join(dir, "m1.mcd"))
+	copyCard(t, "blank.mcd", filepath.Join(dir, "blank.mcd"))
+
+	h := &webdav.Handler{
+		FileSystem: davfs.New(dir),
+		LockSystem: webdav.NewMemLS(),
+	}
+
+	w := do(t, h, http.MethodGet, "/m1.mcd/BASLUS-00603-DASH00", nil)
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+
+	save := w.Body.Bytes()
+
+	w = do(t, h, http.MethodPut, "/blank.mcd/BASLUS-00603-DASH00.mcs", []byte{})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, saves(t, filepath.Join(dir, "blank.mcd")))
+
+	w = do(t, h, http.MethodPut, "/blank.mcd/BASLUS-00603-DASH00.mcs", save)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, []string{"BASLUS-00603-DASH00"}, saves(t, filepath.Join(dir, "blank.mcd")))
+
+	w = do(t, h, http.MethodGet, "/blank.mcd/BASLUS-00603-DASH00", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, save, w.Body.Bytes())
+}
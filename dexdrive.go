@@ -0,0 +1,43 @@
+package psx
+
+import "bytes"
+
+const (
+	dexDriveHeaderSize    = 0xf40
+	dexDriveCommentOffset = 0x40
+)
+
+//nolint:gochecknoglobals
+var dexDriveSignature = [12]byte{'1', '2', '3', '-', '4', '5', '6', '-', 'S', 'T', 'D'}
+
+func detectDexDrive(b []byte) bool {
+	return bytes.HasPrefix(b, dexDriveSignature[:])
+}
+
+// dexDriveHeader generates the header prepended to the memory card image.
+// Besides the signature it contains a copy of the first byte and the low
+// byte of the link order of each directory frame, followed by a 256 byte
+// comment for each block which is copied from prev, if possible, otherwise
+// left empty.
+func dexDriveHeader(b, prev []byte) ([]byte, error) {
+	h := make([]byte, dexDriveHeaderSize)
+
+	if len(prev) == dexDriveHeaderSize && detectDexDrive(prev) {
+		copy(h[dexDriveCommentOffset:], prev[dexDriveCommentOffset:])
+	}
+
+	copy(h, dexDriveSignature[:])
+
+	h[0x12] = 0x01
+	h[0x14] = 0x01
+	h[0x15] = 'M'
+
+	for i := 0; i < numBlocks; i++ {
+		df := b[(i+1)*frameSize:]
+
+		h[0x16+i] = df[0]
+		h[0x26+i] = df[8]
+	}
+
+	return h, nil
+}
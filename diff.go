@@ -0,0 +1,186 @@
+package psx
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// A Change describes how a file differs between two memory cards.
+type Change int
+
+const (
+	// Added means the file only exists on the second memory card.
+	Added Change = iota + 1
+	// Removed means the file only exists on the first memory card.
+	Removed
+	// Modified means the file exists on both memory cards but differs.
+	Modified
+)
+
+func (c Change) String() string {
+	switch c {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	default:
+		return fmt.Sprintf("Change(%d)", int(c))
+	}
+}
+
+// A FileDiff describes how a file differs between two memory cards.
+type FileDiff struct {
+	Name   string
+	Change Change
+	// OldSize and NewSize are the sizes of the file on the first and
+	// second memory card respectively, zero if it doesn't exist.
+	OldSize int64
+	NewSize int64
+	// Blocks lists the positions of the blocks within a modified file,
+	// counting from zero, whose contents differ. If the file has changed
+	// size, any blocks only present on one memory card are included.
+	Blocks []int
+	// Title is true if the title of a modified file differs.
+	Title bool
+	// Icon is true if the icon of a modified file differs.
+	Icon bool
+}
+
+// A CardDiff describes how two memory cards differ.
+type CardDiff struct {
+	// Files lists the added, removed and modified files, sorted by name.
+	Files []FileDiff
+	// OldBrokenSectors and NewBrokenSectors list the broken sectors
+	// recorded on the first and second memory card respectively.
+	OldBrokenSectors []uint32
+	NewBrokenSectors []uint32
+	// Header is true if the header frames differ.
+	Header bool
+}
+
+// Equal returns true if no differences were found.
+func (d *CardDiff) Equal() bool {
+	return len(d.Files) == 0 && !d.Header && !d.BrokenSectors()
+}
+
+// BrokenSectors returns true if the broken sector lists differ.
+func (d *CardDiff) BrokenSectors() bool {
+	if len(d.OldBrokenSectors) != len(d.NewBrokenSectors) {
+		return true
+	}
+
+	for i := range d.OldBrokenSectors {
+		if d.OldBrokenSectors[i] != d.NewBrokenSectors[i] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Reader) brokenSectors() []uint32 {
+	var sectors []uint32
+
+	for i := range r.hb.UnusedFrame {
+		if sector, ok := r.hb.UnusedFrame[i].brokenSector(); ok {
+			sectors = append(sectors, sector)
+		}
+	}
+
+	return sectors
+}
+
+func (r *Reader) fileMap() map[string]*File {
+	files := make(map[string]*File, len(r.File))
+
+	for _, f := range r.File {
+		if _, ok := files[f.Name]; !ok {
+			files[f.Name] = f
+		}
+	}
+
+	return files
+}
+
+func diffFile(a, b *File) (FileDiff, bool, error) {
+	d := FileDiff{
+		Name:    a.Name,
+		Change:  Modified,
+		OldSize: a.Size,
+		NewSize: b.Size,
+	}
+
+	ab, bb := a.blocks(), b.blocks()
+
+	for i := 0; i < len(ab) || i < len(bb); i++ {
+		if i >= len(ab) || i >= len(bb) {
+			d.Blocks = append(d.Blocks, i)
+
+			continue
+		}
+
+		x, err := a.r.block(ab[i])
+		if err != nil {
+			return d, false, err
+		}
+
+		y, err := b.r.block(bb[i])
+		if err != nil {
+			return d, false, err
+		}
+
+		if !bytes.Equal(x, y) {
+			d.Blocks = append(d.Blocks, i)
+		}
+
+		// The title and icon are always in the first block
+		if i == 0 {
+			d.Title = !bytes.Equal(titleBytes(x), titleBytes(y))
+			d.Icon = !bytes.Equal(iconBytes(x), iconBytes(y))
+		}
+	}
+
+	return d, d.OldSize != d.NewSize || len(d.Blocks) > 0, nil
+}
+
+// Diff compares the memory cards read by a and b. Files are matched by name.
+func Diff(a, b *Reader) (*CardDiff, error) {
+	d := &CardDiff{
+		OldBrokenSectors: a.brokenSectors(),
+		NewBrokenSectors: b.brokenSectors(),
+		Header:           a.hb.HeaderFrame != b.hb.HeaderFrame || a.hb.TrailingFrame != b.hb.TrailingFrame,
+	}
+
+	af, bf := a.fileMap(), b.fileMap()
+
+	for name, f := range af {
+		other, ok := bf[name]
+		if !ok {
+			d.Files = append(d.Files, FileDiff{Name: name, Change: Removed, OldSize: f.Size})
+
+			continue
+		}
+
+		fd, ok, err := diffFile(f, other)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			d.Files = append(d.Files, fd)
+		}
+	}
+
+	for name, f := range bf {
+		if _, ok := af[name]; !ok {
+			d.Files = append(d.Files, FileDiff{Name: name, Change: Added, NewSize: f.Size})
+		}
+	}
+
+	sort.Slice(d.Files, func(i, j int) bool { return d.Files[i].Name < d.Files[j].Name })
+
+	return d, nil
+}
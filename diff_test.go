@@ -0,0 +1,160 @@
+package psx_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	b, err := os.ReadFile(filepath.Join("testdata", "MemoryCard2-1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blank, err := os.ReadFile(filepath.Join("testdata", "blank.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modified := append([]byte{}, b...)
+	// Change the title and second block of the first file
+	modified[blockSize+4] ^= 0xff
+	modified[2*blockSize] ^= 0xff
+
+	tables := []struct {
+		name  string
+		a, b  []byte
+		files []psx.FileDiff
+	}{
+		{
+			name: "same",
+			a:    b,
+			b:    b,
+		},
+		{
+			name: "modified",
+			a:    b,
+			b:    modified,
+			files: []psx.FileDiff{
+				{
+					Name:    "BESCES-00984GT",
+					Change:  psx.Modified,
+					OldSize: 41088,
+					NewSize: 41088,
+					Blocks:  []int{0, 1},
+					Title:   true,
+				},
+			},
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			a, err := psx.NewReader(bytes.NewReader(table.a))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := psx.NewReader(bytes.NewReader(table.b))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d, err := psx.Diff(a, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, table.files, d.Files)
+			assert.Equal(t, len(table.files) == 0, d.Equal())
+		})
+	}
+
+	t.Run("added", func(t *testing.T) {
+		t.Parallel()
+
+		a, err := psx.NewReader(bytes.NewReader(blank))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := psx.NewReader(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := psx.Diff(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Len(t, d.Files, len(b.File))
+
+		for _, fd := range d.Files {
+			assert.Equal(t, psx.Added, fd.Change)
+		}
+
+		d, err = psx.Diff(b, a)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Len(t, d.Files, len(b.File))
+
+		for _, fd := range d.Files {
+			assert.Equal(t, psx.Removed, fd.Change)
+		}
+	})
+}
+
+func TestDiffBrokenSectors(t *testing.T) {
+	t.Parallel()
+
+	a, err := psx.OpenReader(filepath.Join("testdata", "m1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	b, err := psx.OpenReader(filepath.Join("testdata", "dirty.mcr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	d, err := psx.Diff(&a.Reader, &b.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.False(t, d.Equal())
+	assert.True(t, d.BrokenSectors())
+	assert.Empty(t, d.OldBrokenSectors)
+	assert.Equal(t, []uint32{0x0c, 0x1f0}, d.NewBrokenSectors)
+	assert.True(t, d.Header)
+	assert.Equal(t, []psx.FileDiff{
+		{
+			Name:    "BASLUS-01040VAG1",
+			Change:  psx.Removed,
+			OldSize: 3*blockSize + 128,
+		},
+	}, d.Files)
+
+	d, err = psx.Diff(&b.Reader, &a.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []uint32{0x0c, 0x1f0}, d.OldBrokenSectors)
+	assert.Empty(t, d.NewBrokenSectors)
+}
@@ -3,38 +3,42 @@ package psx
 import (
 	"bytes"
 	"encoding/binary"
-	"errors"
-	"io"
-
-	"github.com/bodgit/psx/internal/xor"
 )
 
-var errBadDirectoryChecksum = errors.New("bad directory frame checksum")
+// Offsets of the filename fields within a directory frame.
+const (
+	filenameOffset   = 0x0a
+	identifierOffset = 0x16
+	filenameSize     = 0x14
+)
 
+// The reserved and padding bytes are kept so that a directory frame is always
+// marshalled exactly as it was read.
 type directoryFrame struct {
 	AvailableBlocks byte
-	_               [3]byte
+	Reserved        [3]byte
 	Size            uint32
 	LinkOrder       uint16
 	CountryCode     [2]byte
 	ProductCode     [10]byte
 	Identifier      [8]byte
-	_               [97]byte
+	Padding         [97]byte
 	Checksum        [1]byte
 }
 
-func (df *directoryFrame) unmarshalBinary(r io.Reader) error {
-	h := xor.New()
-
-	if err := binary.Read(checksumReader(r, h, binary.Size(df)), binary.LittleEndian, df); err != nil {
-		return err
-	}
-
-	if !bytes.Equal(df.Checksum[:], h.Sum(nil)) {
-		return errBadDirectoryChecksum
-	}
-
-	return nil
+// decode decodes the directory frame from the frame b.
+func (df *directoryFrame) decode(b []byte) error {
+	df.AvailableBlocks = b[0]
+	copy(df.Reserved[:], b[1:])
+	df.Size = binary.LittleEndian.Uint32(b[4:])
+	df.LinkOrder = binary.LittleEndian.Uint16(b[8:])
+	copy(df.CountryCode[:], b[filenameOffset:])
+	copy(df.ProductCode[:], b[filenameOffset+len(df.CountryCode):])
+	copy(df.Identifier[:], b[identifierOffset:])
+	copy(df.Padding[:], b[filenameOffset+filenameSize:])
+	df.Checksum[0] = b[frameSize-1]
+
+	return verifyChecksum(b, ErrBadDirectoryChecksum)
 }
 
 func (df *directoryFrame) MarshalBinary() ([]byte, error) {
@@ -76,19 +80,24 @@ func (df *directoryFrame) isFirst() bool {
 }
 
 func (df *directoryFrame) countryCode() string {
-	return string(df.CountryCode[:])
+	return cstring(df.CountryCode[:])
 }
 
 func (df *directoryFrame) productCode() string {
-	return string(df.ProductCode[:])
+	return cstring(df.ProductCode[:])
 }
 
 func (df *directoryFrame) identifier() string {
-	return string(df.Identifier[:])
+	return cstring(df.Identifier[:])
 }
 
 func (df *directoryFrame) filename() string {
-	return df.countryCode() + df.productCode() + df.identifier()
+	b := make([]byte, 0, len(df.CountryCode)+len(df.ProductCode)+len(df.Identifier))
+	b = append(b, df.CountryCode[:]...)
+	b = append(b, df.ProductCode[:]...)
+	b = append(b, df.Identifier[:]...)
+
+	return cstring(b)
 }
 
 func newDirectoryFrame() directoryFrame {
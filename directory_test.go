@@ -0,0 +1,72 @@
+package psx_test
+
+import (
+	"bytes"
+	"io/fs"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileNames(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name                                        string
+		countryCode, productCode, identifier, stats string
+	}{
+		{"BASLUS-00000TEST", "BA", "SLUS-00000", "TEST", "BASLUS-00000TEST"},
+		{"BASLUS-00000", "BA", "SLUS-00000", "", "BASLUS-00000"},
+		{"BASLUS-0", "BA", "SLUS-0", "", "BASLUS-0"},
+	}
+
+	for _, table := range tables {
+		table := table
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := new(bytes.Buffer)
+
+			w, err := psx.NewWriter(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fw, err := w.Create()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := fw.Write(newSave(table.name, 1)); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := fw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := psx.NewReader(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The NUL bytes padding each field aren't part of the name
+			if assert.Len(t, r.File, 1) {
+				assert.Equal(t, table.name, r.File[0].Name)
+				assert.Equal(t, table.countryCode, r.File[0].CountryCode)
+				assert.Equal(t, table.productCode, r.File[0].ProductCode)
+				assert.Equal(t, table.identifier, r.File[0].Identifier)
+			}
+
+			fi, err := fs.Stat(r, table.stats)
+			if assert.NoError(t, err) {
+				assert.Equal(t, table.name, fi.Name())
+			}
+		})
+	}
+}
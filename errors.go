@@ -0,0 +1,195 @@
+package psx
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrBadHeaderSignature is returned when a header frame doesn't start
+	// with the expected signature.
+	ErrBadHeaderSignature = errors.New("bad header frame signature")
+	// ErrBadHeaderChecksum is returned when the checksum of a header frame
+	// doesn't match its contents.
+	ErrBadHeaderChecksum = errors.New("bad header frame checksum")
+	// ErrBadDirectoryChecksum is returned when the checksum of a directory
+	// frame doesn't match its contents.
+	ErrBadDirectoryChecksum = errors.New("bad directory frame checksum")
+	// ErrBadUnusedChecksum is returned when the checksum of an unused frame
+	// doesn't match its contents.
+	ErrBadUnusedChecksum = errors.New("bad unused frame checksum")
+	// ErrBadDataSignature is returned when the first data block of a file
+	// doesn't start with the expected signature.
+	ErrBadDataSignature = errors.New("bad data block signature")
+	// ErrBadAllocation is returned when a directory frame has an unknown
+	// block allocation state.
+	ErrBadAllocation = errors.New("invalid block allocation state")
+	// ErrBadLink is returned when a directory frame links to a block that
+	// isn't the next block of the same file.
+	ErrBadLink = errors.New("invalid link order")
+	// ErrOrphanedBlock is returned when a block is marked as part of a file
+	// but no file links to it.
+	ErrOrphanedBlock = errors.New("orphaned block")
+	// ErrTrailingBytes is returned when there is more data than expected
+	// after a memory card image.
+	ErrTrailingBytes = errors.New("trailing bytes")
+	// ErrInvalidLength is returned when the size of a file or memory card
+	// image doesn't match what is expected.
+	ErrInvalidLength = errors.New("invalid length")
+	// ErrNoFreeSpace is returned when there aren't enough free blocks left
+	// on a memory card for a file.
+	ErrNoFreeSpace = errors.New("no free space")
+	// ErrDuplicateName is returned when a file with the same name already
+	// exists on a memory card.
+	ErrDuplicateName = errors.New("duplicate name")
+	// ErrUnknownFormat is returned when a memory card image format isn't
+	// recognised.
+	ErrUnknownFormat = errors.New("unknown format")
+	// ErrUnsupportedFormat is returned when a memory card image format
+	// can be read but not written back.
+	ErrUnsupportedFormat = errors.New("unsupported format")
+	// ErrBadIcon is returned when an icon has the wrong number of frames or
+	// a frame is the wrong size.
+	ErrBadIcon = errors.New("invalid icon")
+	// ErrNotPocketStation is returned when a file doesn't contain a
+	// PocketStation application.
+	ErrNotPocketStation = errors.New("not a PocketStation application")
+	// ErrOutOfRange is returned when a block or frame index is outside of
+	// the memory card.
+	ErrOutOfRange = errors.New("index out of range")
+	// ErrClosed is returned when using a Writer that has been closed.
+	ErrClosed = errors.New("writer closed")
+	// ErrBadSerial is returned when a serial number can't safely be used
+	// as part of a file name.
+	ErrBadSerial = errors.New("invalid serial number")
+	// ErrBadName is returned when a file is renamed to a name that changes
+	// more than its identifier.
+	ErrBadName = errors.New("name must only change the identifier")
+)
+
+func location(frame, block int) string {
+	switch {
+	case block < 0:
+		return fmt.Sprintf("frame %d", frame)
+	case frame < 0:
+		return fmt.Sprintf("block %d", block)
+	default:
+		return fmt.Sprintf("frame %d (block %d)", frame, block)
+	}
+}
+
+// A FrameError records a problem with a specific frame in the header block
+// of a memory card image, or with the data block it describes.
+type FrameError struct {
+	// Frame is the index of the frame within the header block, or -1 if
+	// the problem is with the data block only.
+	Frame int
+	// Block is the index of the data block, or -1 if the frame doesn't
+	// describe a data block.
+	Block int
+	Err   error
+}
+
+func (e *FrameError) Error() string {
+	return location(e.Frame, e.Block) + ": " + e.Err.Error()
+}
+
+func (e *FrameError) Unwrap() error {
+	return e.Err
+}
+
+// A ChecksumError records a frame whose checksum doesn't match its contents.
+// It wraps one of ErrBadHeaderChecksum, ErrBadDirectoryChecksum or
+// ErrBadUnusedChecksum depending on the type of frame.
+type ChecksumError struct {
+	// Frame is the index of the frame within the header block.
+	Frame int
+	// Block is the index of the data block described by the frame, or -1
+	// if the frame doesn't describe a data block.
+	Block int
+	// Expected is the checksum computed from the frame contents.
+	Expected byte
+	// Actual is the checksum stored in the frame.
+	Actual byte
+	Err    error
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s: %s: expected 0x%02x, got 0x%02x", location(e.Frame, e.Block), e.Err, e.Expected, e.Actual)
+}
+
+func (e *ChecksumError) Unwrap() error {
+	return e.Err
+}
+
+// A FileError records a problem with a named file on a memory card.
+type FileError struct {
+	Name string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return e.Name + ": " + e.Err.Error()
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// A LengthError records a file whose length doesn't match what is expected.
+// It wraps ErrInvalidLength.
+type LengthError struct {
+	// Name is the name of the file, if known.
+	Name     string
+	Expected int64
+	Actual   int64
+}
+
+func (e *LengthError) Error() string {
+	s := fmt.Sprintf("%s: expected %d bytes, got %d", ErrInvalidLength, e.Expected, e.Actual)
+	if e.Name != "" {
+		s = e.Name + ": " + s
+	}
+
+	return s
+}
+
+func (e *LengthError) Unwrap() error {
+	return ErrInvalidLength
+}
+
+// A SpaceError records a file that doesn't fit in the remaining free blocks
+// on a memory card. It wraps ErrNoFreeSpace.
+type SpaceError struct {
+	// Name is the name of the file, if known.
+	Name string
+	// Required is the number of blocks needed for the file.
+	Required int
+	// Available is the number of free blocks remaining.
+	Available int
+}
+
+func (e *SpaceError) Error() string {
+	s := fmt.Sprintf("%s: %d blocks required, %d available", ErrNoFreeSpace, e.Required, e.Available)
+	if e.Name != "" {
+		s = e.Name + ": " + s
+	}
+
+	return s
+}
+
+func (e *SpaceError) Unwrap() error {
+	return ErrNoFreeSpace
+}
+
+// locate records the position of err within the header block.
+func locate(err error, frame, block int) error {
+	var ce *ChecksumError
+	if errors.As(err, &ce) {
+		ce.Frame, ce.Block = frame, block
+
+		return ce
+	}
+
+	return &FrameError{Frame: frame, Block: block, Err: err}
+}
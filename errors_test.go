@@ -0,0 +1,116 @@
+package psx_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksumError(t *testing.T) {
+	t.Parallel()
+
+	b, err := os.ReadFile(filepath.Join("testdata", "MemoryCard2-1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	directoryFrame(b, 4)[frameSize-1] ^= 0xff
+
+	_, err = psx.NewReader(bytes.NewReader(b))
+	assert.ErrorIs(t, err, psx.ErrBadDirectoryChecksum)
+
+	var ce *psx.ChecksumError
+	if assert.True(t, errors.As(err, &ce)) {
+		assert.Equal(t, 5, ce.Frame)
+		assert.Equal(t, 4, ce.Block)
+		assert.Equal(t, ce.Expected^0xff, ce.Actual)
+	}
+}
+
+// newSave returns a minimal save of the given number of blocks, prefixed with
+// its directory frame.
+func newSave(name string, blocks int) []byte {
+	b := make([]byte, frameSize+blocks*blockSize)
+
+	b[0] = 0x51
+	binary.LittleEndian.PutUint32(b[4:], uint32(blocks*blockSize))
+	binary.LittleEndian.PutUint16(b[8:], 0xffff)
+	copy(b[10:30], name)
+	fixChecksum(b[:frameSize])
+	copy(b[frameSize:], "SC")
+
+	return b
+}
+
+func copyFile(w *psx.Writer, f *psx.File) error {
+	fr, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer fr.Close()
+
+	fw, err := w.Create()
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(fw, fr); err != nil {
+		return err
+	}
+
+	return fw.Close()
+}
+
+func TestWriterErrors(t *testing.T) {
+	t.Parallel()
+
+	rc, err := psx.OpenReader(filepath.Join("testdata", "MemoryCard2-1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	w, err := psx.NewWriter(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := copyFile(w, rc.File[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	err = copyFile(w, rc.File[0])
+	assert.ErrorIs(t, err, psx.ErrDuplicateName)
+
+	var fe *psx.FileError
+	if assert.True(t, errors.As(err, &fe)) {
+		assert.Equal(t, rc.File[0].Name, fe.Name)
+	}
+
+	fw, err := w.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write(newSave("BASLUS-00000TEST", 15)); err != nil {
+		t.Fatal(err)
+	}
+
+	err = fw.Close()
+	assert.ErrorIs(t, err, psx.ErrNoFreeSpace)
+
+	var se *psx.SpaceError
+	if assert.True(t, errors.As(err, &se)) {
+		assert.Equal(t, "BASLUS-00000TEST", se.Name)
+		assert.Equal(t, 15, se.Required)
+		assert.Equal(t, 10, se.Available)
+	}
+}
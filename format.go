@@ -0,0 +1,269 @@
+package psx
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Format represents the container format used to store a memory card image.
+type Format int
+
+const (
+	// FormatRaw is a raw 128 KiB memory card image, typically using a .mcd
+	// or .mcr extension.
+	FormatRaw Format = iota
+	// FormatDexDrive is the format used by the Connectix DexDrive, typically
+	// using a .gme extension.
+	FormatDexDrive
+	// FormatVMP is the format used by the PSP and PS Vita, typically using a
+	// .vmp extension.
+	FormatVMP
+	// FormatSRM is the format used by the .srm save files of some RetroArch
+	// cores. Beetle PSX and PCSX ReARMed use a raw memory card image, which
+	// is detected as FormatRaw, whereas other cores add extra data before
+	// or after the memory card, such as further memory cards. When reading,
+	// the first memory card found is used and the extra data is ignored.
+	// When writing a memory card read from such an image, the extra data is
+	// written back around it unaltered. Otherwise there's nothing to add so
+	// the result is the same as FormatRaw, which is also what the .srm
+	// extension maps to as that's what Beetle PSX and PCSX ReARMed expect.
+	FormatSRM
+)
+
+// maxImageSize is the largest memory card image that will be read.
+const maxImageSize = 64 * cardSize
+
+// A format with no header function can't be written. The header function is
+// passed the memory card and the header it was originally read with, if any,
+// so that anything not derived from the memory card can be kept. Anything
+// that originally followed the memory card is written back after it.
+type format struct {
+	name       string
+	extensions []string
+	locate     func(io.ReaderAt, int64, []byte) (int64, bool, error)
+	header     func([]byte, []byte) ([]byte, error)
+}
+
+// Formats are detected in order so FormatSRM must be last as it accepts
+// anything containing a memory card.
+//
+//nolint:gochecknoglobals
+var formats = [...]format{
+	FormatRaw: {
+		name:       "raw",
+		extensions: []string{".mcd", ".mcr", ".mc", ".ddf", ".mem", ".ps", ".psm", ".bin", ".srm"},
+		locate: fixedHeader(0, func([]byte) bool {
+			return true
+		}),
+		header: noHeader,
+	},
+	FormatDexDrive: {
+		name:       "dexdrive",
+		extensions: []string{".gme"},
+		locate:     fixedHeader(dexDriveHeaderSize, detectDexDrive),
+		header:     dexDriveHeader,
+	},
+	FormatVMP: {
+		name:       "vmp",
+		extensions: []string{".vmp"},
+		locate:     fixedHeader(vmpHeaderSize, detectVMP),
+		header:     vmpHeader,
+	},
+	FormatSRM: {
+		name:       "srm",
+		extensions: []string{".srm"},
+		locate:     locateSRM,
+		header:     srmHeader,
+	},
+}
+
+func noHeader([]byte, []byte) ([]byte, error) {
+	return nil, nil
+}
+
+// fixedHeader returns a function that locates the memory card following a
+// header of size bytes recognised by detect, which is passed the first frame.
+func fixedHeader(size int, detect func([]byte) bool) func(io.ReaderAt, int64, []byte) (int64, bool, error) {
+	return func(r io.ReaderAt, n int64, buf []byte) (int64, bool, error) {
+		if n != int64(size+cardSize) {
+			return 0, false, nil
+		}
+
+		if _, err := r.ReadAt(buf, 0); err != nil {
+			return 0, false, fmt.Errorf("unable to read header: %w", err)
+		}
+
+		if !detect(buf) {
+			return 0, false, nil
+		}
+
+		if size > 0 {
+			if _, err := r.ReadAt(buf, int64(size)); err != nil {
+				return 0, false, fmt.Errorf("unable to read header: %w", err)
+			}
+		}
+
+		return int64(size), bytes.HasPrefix(buf, headerSignature[:]), nil
+	}
+}
+
+func (f Format) format() (format, bool) {
+	if f < 0 || int(f) >= len(formats) {
+		return format{}, false
+	}
+
+	return formats[f], true
+}
+
+// writable returns an error if memory card images can't be written using f.
+func (f Format) writable() error {
+	v, ok := f.format()
+
+	switch {
+	case !ok:
+		return ErrUnknownFormat
+	case v.header == nil:
+		return fmt.Errorf("unable to write %s image: %w", v.name, ErrUnsupportedFormat)
+	}
+
+	return nil
+}
+
+// String returns the name of the format.
+func (f Format) String() string {
+	if v, ok := f.format(); ok {
+		return v.name
+	}
+
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// Extension returns the preferred file extension for the format, including
+// the leading dot. FormatByExtension may not return the same Format for it.
+func (f Format) Extension() string {
+	if v, ok := f.format(); ok {
+		return v.extensions[0]
+	}
+
+	return ""
+}
+
+// ParseFormat returns the Format matching the name s as returned by
+// Format.String.
+func ParseFormat(s string) (Format, error) {
+	for k, v := range formats {
+		if strings.EqualFold(v.name, s) {
+			return Format(k), nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %s", ErrUnknownFormat, s)
+}
+
+// FormatByExtension returns the Format typically used when writing files
+// with the same extension as name.
+func FormatByExtension(name string) (Format, bool) {
+	ext := strings.ToLower(filepath.Ext(name))
+
+	for k, v := range formats {
+		for _, e := range v.extensions {
+			if e == ext {
+				return Format(k), true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// wrap prepends the header for the format to the memory card b. prev and next
+// are whatever came before and after b when it was originally read, or nil.
+func (f Format) wrap(b, prev, next []byte) ([]byte, error) {
+	if err := f.writable(); err != nil {
+		return nil, err
+	}
+
+	v := formats[f]
+
+	h, err := v.header(b, prev)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal %s header: %w", v.name, err)
+	}
+
+	return append(append(h, b...), next...), nil
+}
+
+func (f Format) marshalBinary(mc *memoryCard, prev, next []byte) ([]byte, error) {
+	b, err := mc.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return f.wrap(b, prev, next)
+}
+
+// detectFormat works out which Format is used by the memory card image of
+// size bytes read by r and returns the offset of the memory card within it.
+// buf is used as scratch space and must be one frame in size.
+func detectFormat(r io.ReaderAt, size int64, buf []byte) (Format, int64, bool, error) {
+	if size < cardSize || size > maxImageSize {
+		return 0, 0, false, nil
+	}
+
+	for k, v := range formats {
+		offset, ok, err := v.locate(r, size, buf)
+		if err != nil {
+			return 0, 0, false, err
+		}
+
+		if ok {
+			return Format(k), offset, true, nil
+		}
+	}
+
+	return 0, 0, false, nil
+}
+
+// DetectFormat works out which Format is used by the io.ReaderAt r pointing
+// to the data of size bytes. If the data doesn't look like a supported
+// memory card image then ok is false.
+func DetectFormat(r io.ReaderAt, size int64) (f Format, ok bool, err error) {
+	f, _, ok, err = detectFormat(r, size, make([]byte, frameSize))
+
+	return f, ok, err
+}
+
+// Convert reads a memory card image in any supported format from r and
+// writes it to w using format f. The memory card image is copied verbatim,
+// along with anything else in the image if it's already using format f.
+func Convert(w io.Writer, f Format, r io.Reader) error {
+	src, b, offset, err := readImage(r)
+	if err != nil {
+		return err
+	}
+
+	card := b[offset : offset+cardSize]
+
+	// Make sure the memory card is valid
+	if err := new(memoryCard).UnmarshalBinary(card); err != nil {
+		return err
+	}
+
+	var prev, next []byte
+	if src == f {
+		prev, next = b[:offset], b[offset+cardSize:]
+	}
+
+	if b, err = f.wrap(card, prev, next); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(b); err != nil {
+		return fmt.Errorf("unable to write memory card: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package psx_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		format psx.Format
+		size   int
+	}{
+		{
+			psx.FormatRaw,
+			131072,
+		},
+		{
+			psx.FormatDexDrive,
+			134976,
+		},
+		{
+			psx.FormatVMP,
+			131200,
+		},
+	}
+
+	b, err := os.ReadFile(filepath.Join("testdata", "MemoryCard2-1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, table := range tables {
+		table := table
+		t.Run(table.format.String(), func(t *testing.T) {
+			t.Parallel()
+
+			buf := new(bytes.Buffer)
+
+			if err := psx.Convert(buf, table.format, bytes.NewReader(b)); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, table.size, buf.Len())
+
+			f, ok, err := psx.DetectFormat(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.True(t, ok)
+			assert.Equal(t, table.format, f)
+
+			r, err := psx.NewReader(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, table.format, r.Format())
+			assert.Len(t, r.File, 10)
+
+			raw := new(bytes.Buffer)
+
+			if err := psx.Convert(raw, psx.FormatRaw, buf); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, b, raw.Bytes())
+		})
+	}
+}
+
+func TestFormatByExtension(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name   string
+		format psx.Format
+		ok     bool
+	}{
+		{"card.mcd", psx.FormatRaw, true},
+		{"CARD.MCR", psx.FormatRaw, true},
+		{"card.gme", psx.FormatDexDrive, true},
+		{"card.vmp", psx.FormatVMP, true},
+		{"card.srm", psx.FormatRaw, true},
+		{"card.txt", 0, false},
+	}
+
+	for _, table := range tables {
+		table := table
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			f, ok := psx.FormatByExtension(table.name)
+			assert.Equal(t, table.ok, ok)
+			assert.Equal(t, table.format, f)
+		})
+	}
+}
+
+func TestSRM(t *testing.T) {
+	t.Parallel()
+
+	b, err := os.ReadFile(filepath.Join("testdata", "MemoryCard2-1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blank, err := os.ReadFile(filepath.Join("testdata", "blank.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []struct {
+		name string
+		b    []byte
+	}{
+		{"multiple cards", bytes.Join([][]byte{b, blank}, nil)},
+		{"prefix", bytes.Join([][]byte{make([]byte, 0x200), b}, nil)},
+		{"suffix", bytes.Join([][]byte{b, []byte("RetroArch")}, nil)},
+	}
+
+	for _, table := range tables {
+		table := table
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			f, ok, err := psx.DetectFormat(bytes.NewReader(table.b), int64(len(table.b)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.True(t, ok)
+			assert.Equal(t, psx.FormatSRM, f)
+
+			r, err := psx.NewReader(bytes.NewReader(table.b))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, psx.FormatSRM, r.Format())
+			assert.Len(t, r.File, 10)
+
+			buf := new(bytes.Buffer)
+
+			if err := psx.Convert(buf, psx.FormatRaw, bytes.NewReader(table.b)); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, b, buf.Bytes())
+
+			// Everything around the memory card is kept
+			buf.Reset()
+
+			if err := psx.Convert(buf, psx.FormatSRM, bytes.NewReader(table.b)); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, table.b, buf.Bytes())
+
+			// There's nothing else to add to a raw memory card
+			buf.Reset()
+
+			if err := psx.Convert(buf, psx.FormatSRM, bytes.NewReader(b)); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, b, buf.Bytes())
+		})
+	}
+}
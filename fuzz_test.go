@@ -0,0 +1,85 @@
+package psx_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bodgit/psx"
+)
+
+// exercise reads everything it can from the memory card image b, which
+// shouldn't panic or hang no matter what b contains.
+func exercise(t *testing.T, b []byte) {
+	t.Helper()
+
+	_, _ = psx.Check(bytes.NewReader(b))
+	_, _ = psx.Repair(io.Discard, bytes.NewReader(b))
+	_, _ = psx.NewContainerReader(bytes.NewReader(b))
+	_ = psx.NewScanner().Scan(bytes.NewReader(b), int64(len(b)))
+
+	r, err := psx.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return
+	}
+
+	_ = r.DirectoryFrames()
+	_ = r.UnusedFrames()
+
+	for _, f := range r.File {
+		_, _ = f.Title()
+		_, _ = f.Hash()
+		_, _ = f.PocketStation()
+
+		fr, err := f.Open()
+		if err != nil {
+			continue
+		}
+
+		_, _ = io.Copy(io.Discard, fr)
+		fr.Close()
+	}
+
+	_, _ = psx.Diff(r, r)
+	_, _ = psx.Dedupe(r)
+}
+
+// addSeeds adds the first size bytes of each test memory card as a seed.
+func addSeeds(f *testing.F, size int) {
+	f.Helper()
+
+	for _, file := range []string{"blank.mcd", "m1.mcd", "MemoryCard2-1.mcd"} {
+		b, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			f.Fatal(err)
+		}
+
+		f.Add(b[:size])
+	}
+}
+
+func FuzzNewReader(f *testing.F) {
+	addSeeds(f, 16*blockSize)
+
+	f.Fuzz(exercise)
+}
+
+// FuzzHeaderBlock only fuzzes the header block, which is where all of the
+// interesting structure is, and so gets much further than FuzzNewReader.
+func FuzzHeaderBlock(f *testing.F) {
+	addSeeds(f, blockSize)
+
+	f.Fuzz(func(t *testing.T, header []byte) {
+		b := make([]byte, 16*blockSize)
+		copy(b, header)
+
+		// Keep the data signature of each block so files are recognised
+		for i := 1; i < 16; i++ {
+			copy(b[i*blockSize:], "SC")
+		}
+
+		exercise(t, b)
+	})
+}
@@ -1,8 +1,13 @@
 module github.com/bodgit/psx
 
-go 1.17
+go 1.18
 
-require github.com/stretchr/testify v1.9.0
+require (
+	github.com/stretchr/testify v1.9.0
+	golang.org/x/image v0.12.0
+	golang.org/x/net v0.17.0
+	golang.org/x/text v0.13.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
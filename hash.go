@@ -0,0 +1,70 @@
+package psx
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// Hash returns the SHA-256 hash of the contents of the file. Only the name
+// and size are used from the directory frame, along with the data blocks in
+// order, so the hash doesn't depend on where the file is stored on the memory
+// card. Identical saves on different memory cards have the same hash.
+func (f *File) Hash() ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+
+	h := sha256.New()
+
+	df := &f.r.hb.DirectoryFrame[f.i]
+
+	var size [4]byte
+
+	binary.LittleEndian.PutUint32(size[:], df.Size)
+
+	_, _ = h.Write(size[:])
+	_, _ = h.Write([]byte(f.Name))
+	_, _ = h.Write([]byte{0})
+
+	for _, i := range f.blocks() {
+		b, err := f.r.block(i)
+		if err != nil {
+			return sum, err
+		}
+
+		_, _ = h.Write(b)
+	}
+
+	copy(sum[:], h.Sum(nil))
+
+	return sum, nil
+}
+
+// Dedupe groups the files on the memory cards read by rs by their contents,
+// using File.Hash. Each group holds every copy of a save, in the order they
+// were found, and the groups are in the order each save was first found, so
+// a group with more than one file contains duplicates.
+func Dedupe(rs ...*Reader) ([][]*File, error) {
+	var (
+		groups [][]*File
+		seen   = make(map[[sha256.Size]byte]int)
+	)
+
+	for _, r := range rs {
+		for _, f := range r.File {
+			sum, err := f.Hash()
+			if err != nil {
+				return nil, err
+			}
+
+			if i, ok := seen[sum]; ok {
+				groups[i] = append(groups[i], f)
+
+				continue
+			}
+
+			seen[sum] = len(groups)
+			groups = append(groups, []*File{f})
+		}
+	}
+
+	return groups, nil
+}
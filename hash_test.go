@@ -0,0 +1,86 @@
+package psx_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHash(t *testing.T) {
+	t.Parallel()
+
+	m1, err := os.ReadFile(filepath.Join("testdata", "m1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mc2, err := os.ReadFile(filepath.Join("testdata", "MemoryCard2-1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := psx.NewReader(bytes.NewReader(m1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Copy the files in reverse order so each ends up in a different slot
+	buf := new(bytes.Buffer)
+
+	w, err := psx.NewWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := len(r.File) - 1; i >= 0; i-- {
+		if err := copyFile(w, r.File[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	modified := append([]byte{}, m1...)
+	modified[2*blockSize-1] ^= 0xff
+
+	rs := openReaders(t, m1, buf.Bytes(), mc2, modified)
+
+	a, err := rs[0].File[0].Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := rs[1].File[len(rs[1].File)-1].Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, a, b)
+
+	groups, err := psx.Dedupe(rs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every file on m1 is duplicated by the copy, and by the modified copy
+	// except for the first file
+	if assert.Len(t, groups, 21) {
+		assert.Len(t, groups[0], 2)
+		assert.Len(t, groups[1], 3)
+		assert.Len(t, groups[len(groups)-1], 1)
+
+		for _, group := range groups[1:10] {
+			assert.Len(t, group, 3)
+
+			for _, f := range group {
+				assert.Equal(t, group[0].Name, f.Name)
+			}
+		}
+	}
+}
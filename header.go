@@ -3,41 +3,27 @@ package psx
 import (
 	"bytes"
 	"encoding/binary"
-	"errors"
-	"io"
-
-	"github.com/bodgit/psx/internal/xor"
 )
 
-var (
-	headerSignature = [2]byte{'M', 'C'} //nolint:gochecknoglobals
-
-	errBadHeaderChecksum  = errors.New("bad header frame checksum")
-	errBadHeaderSignature = errors.New("bad header frame signature")
-)
+var headerSignature = [2]byte{'M', 'C'} //nolint:gochecknoglobals
 
 type headerFrame struct {
 	Signature [2]byte
-	_         [125]byte
+	Padding   [125]byte
 	Checksum  [1]byte
 }
 
-func (hf *headerFrame) unmarshalBinary(r io.Reader) error {
-	h := xor.New()
-
-	if err := binary.Read(checksumReader(r, h, binary.Size(hf)), binary.LittleEndian, hf); err != nil {
-		return err
-	}
+// decode decodes the header frame from the frame b.
+func (hf *headerFrame) decode(b []byte) error {
+	copy(hf.Signature[:], b)
+	copy(hf.Padding[:], b[len(hf.Signature):])
+	hf.Checksum[0] = b[frameSize-1]
 
 	if !bytes.Equal(hf.Signature[:], headerSignature[:]) {
-		return errBadHeaderSignature
-	}
-
-	if !bytes.Equal(hf.Checksum[:], h.Sum(nil)) {
-		return errBadHeaderChecksum
+		return ErrBadHeaderSignature
 	}
 
-	return nil
+	return verifyChecksum(b, ErrBadHeaderChecksum)
 }
 
 func (hf *headerFrame) MarshalBinary() ([]byte, error) {
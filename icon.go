@@ -0,0 +1,363 @@
+package psx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	_ "image/png" // Register PNG for DecodeIcon
+	"io"
+	"sort"
+)
+
+// Icons are 16x16 pixels with four bits per pixel, the leftmost pixel of each
+// pair in the low nibble, indexing a palette of 16 colours shared by every
+// frame.
+const (
+	iconSize    = 16
+	iconColours = paletteSize / 2
+	stpBit      = 0x8000
+)
+
+// An IconDisplay is the icon display flag of a title frame, which sets how
+// many icon frames there are.
+type IconDisplay byte
+
+const (
+	// IconStatic is a single icon frame.
+	IconStatic IconDisplay = iota + 0x11
+	// IconAnimated2 is an animation of two icon frames.
+	IconAnimated2
+	// IconAnimated3 is an animation of three icon frames.
+	IconAnimated3
+)
+
+// Frames returns the number of icon frames, or zero if d isn't valid.
+func (d IconDisplay) Frames() int {
+	return iconFrames(byte(d))
+}
+
+// rgb555 converts c to a 15-bit colour. Mostly transparent colours become
+// zero, which is drawn as transparent, so opaque black sets the
+// semi-transparency bit to tell them apart.
+func rgb555(c color.Color) uint16 {
+	nc, _ := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if nc.A < 0x80 { //nolint:gomnd
+		return 0
+	}
+
+	v := uint16(nc.R>>3) | uint16(nc.G>>3)<<5 | uint16(nc.B>>3)<<10
+	if v == 0 {
+		v = stpBit
+	}
+
+	return v
+}
+
+// rgb888 is the inverse of rgb555.
+func rgb888(v uint16) color.Color {
+	if v == 0 {
+		return color.Transparent
+	}
+
+	expand := func(i int) uint8 {
+		x := uint8(channel(v, i))
+
+		return x<<3 | x>>2 //nolint:gomnd
+	}
+
+	return color.NRGBA{expand(0), expand(1), expand(2), 0xff}
+}
+
+func channel(c uint16, i int) int {
+	return int(c>>(5*i)) & 0x1f //nolint:gomnd
+}
+
+type colourCount struct {
+	c uint16
+	n int
+}
+
+// A colourBox is a set of colours used by the median cut quantiser.
+type colourBox []colourCount
+
+// widest returns the colour channel with the largest range and its range.
+func (b colourBox) widest() (int, int) {
+	var best, width int
+
+	for i := 0; i < 3; i++ {
+		lo, hi := 0x1f, 0
+
+		for _, cc := range b {
+			if x := channel(cc.c, i); x < lo {
+				lo = x
+			}
+
+			if x := channel(cc.c, i); x > hi {
+				hi = x
+			}
+		}
+
+		if hi-lo > width {
+			best, width = i, hi-lo
+		}
+	}
+
+	return best, width
+}
+
+// split divides the box at the median pixel along its widest channel.
+func (b colourBox) split() (colourBox, colourBox) {
+	i, _ := b.widest()
+
+	sort.Slice(b, func(x, y int) bool {
+		return channel(b[x].c, i) < channel(b[y].c, i)
+	})
+
+	var total, sum int
+	for _, cc := range b {
+		total += cc.n
+	}
+
+	m := 1
+	for ; m < len(b)-1; m++ {
+		if sum += b[m-1].n; 2*sum >= total {
+			break
+		}
+	}
+
+	return b[:m], b[m:]
+}
+
+// mean returns the average colour of the pixels in the box.
+func (b colourBox) mean() uint16 {
+	var sum [3]int
+
+	total := 0
+
+	for _, cc := range b {
+		for i := range sum {
+			sum[i] += channel(cc.c, i) * cc.n
+		}
+
+		total += cc.n
+	}
+
+	v := uint16(sum[0]/total | sum[1]/total<<5 | sum[2]/total<<10)
+	if v == 0 {
+		v = stpBit
+	}
+
+	return v
+}
+
+// quantise reduces the colours to at most n using median cut. The result is
+// sorted so the same colours always produce the same palette.
+func quantise(counts map[uint16]int, n int) []uint16 {
+	box := make(colourBox, 0, len(counts))
+	for c, count := range counts {
+		box = append(box, colourCount{c, count})
+	}
+
+	sort.Slice(box, func(i, j int) bool {
+		return box[i].c < box[j].c
+	})
+
+	if len(box) <= n {
+		palette := make([]uint16, len(box))
+		for i, cc := range box {
+			palette[i] = cc.c
+		}
+
+		return palette
+	}
+
+	boxes := []colourBox{box}
+
+	for len(boxes) < n {
+		best, width := -1, 0
+
+		for i, b := range boxes {
+			if _, w := b.widest(); len(b) > 1 && w > width {
+				best, width = i, w
+			}
+		}
+
+		if best < 0 {
+			break
+		}
+
+		a, b := boxes[best].split()
+		boxes[best] = a
+		boxes = append(boxes, b)
+	}
+
+	palette := make([]uint16, len(boxes))
+	for i, b := range boxes {
+		palette[i] = b.mean()
+	}
+
+	sort.Slice(palette, func(i, j int) bool {
+		return palette[i] < palette[j]
+	})
+
+	return palette
+}
+
+// nearest returns the index of the palette entry closest to c. Transparency
+// only matches transparency.
+func nearest(palette []uint16, c uint16) int {
+	best, distance := 0, -1
+
+	for i, p := range palette {
+		if (p == 0) != (c == 0) {
+			continue
+		}
+
+		d := 0
+
+		for j := 0; j < 3; j++ {
+			x := channel(p, j) - channel(c, j)
+			d += x * x
+		}
+
+		if distance < 0 || d < distance {
+			best, distance = i, d
+		}
+	}
+
+	return best
+}
+
+// encodeIcon quantises the icon frames to a shared palette of 16 colours and
+// returns the encoded palette and frames.
+func encodeIcon(frames []image.Image) ([]byte, []byte, error) {
+	counts := make(map[uint16]int)
+
+	for i, img := range frames {
+		if b := img.Bounds(); b.Dx() != iconSize || b.Dy() != iconSize {
+			return nil, nil, fmt.Errorf("icon frame %d is %dx%d: %w", i, b.Dx(), b.Dy(), ErrBadIcon)
+		}
+
+		forEachPixel(img, func(_, _ int, c uint16) {
+			counts[c]++
+		})
+	}
+
+	n := iconColours
+	if _, ok := counts[0]; ok {
+		// Transparency always gets its own palette entry
+		n--
+
+		delete(counts, 0)
+	}
+
+	palette := quantise(counts, n)
+	if n < iconColours {
+		palette = append([]uint16{0}, palette...)
+	}
+
+	pb := make([]byte, paletteSize)
+	for i, c := range palette {
+		binary.LittleEndian.PutUint16(pb[i*2:], c)
+	}
+
+	b := make([]byte, len(frames)*iconFrameSize)
+
+	for i, img := range frames {
+		frame := b[i*iconFrameSize:]
+
+		forEachPixel(img, func(x, y int, c uint16) {
+			frame[y*iconSize/2+x/2] |= byte(nearest(palette, c) << (x % 2 * 4)) //nolint:gomnd
+		})
+	}
+
+	return pb, b, nil
+}
+
+// forEachPixel calls fn with the position and 15-bit colour of each pixel of
+// img, relative to its top left corner.
+func forEachPixel(img image.Image, fn func(int, int, uint16)) {
+	b := img.Bounds()
+
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			fn(x, y, rgb555(img.At(b.Min.X+x, b.Min.Y+y)))
+		}
+	}
+}
+
+// decodeIcon decodes the icon frames from the first block of a file.
+func decodeIcon(b []byte) []image.Image {
+	palette := make(color.Palette, iconColours)
+	for i := range palette {
+		palette[i] = rgb888(binary.LittleEndian.Uint16(b[paletteOffset+i*2:]))
+	}
+
+	frames := make([]image.Image, iconFrames(b[iconDisplayOffset]))
+
+	for i := range frames {
+		img := image.NewPaletted(image.Rect(0, 0, iconSize, iconSize), palette)
+
+		for j, x := range b[iconOffset+i*iconFrameSize : iconOffset+(i+1)*iconFrameSize] {
+			img.Pix[j*2], img.Pix[j*2+1] = x&0x0f, x>>4 //nolint:gomnd
+		}
+
+		frames[i] = img
+	}
+
+	return frames
+}
+
+// Icon returns the icon frames of the file shown by the memory card manager.
+func (f *File) Icon() ([]image.Image, error) {
+	b, err := f.r.block(f.i)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeIcon(b), nil
+}
+
+// DecodeIcon decodes icon frames suitable for TitleFrame or SetIcon from r.
+// Each frame of an animated GIF is a separate icon frame, any other image
+// format registered with the image package, such as PNG, is a single frame.
+func DecodeIcon(r io.Reader) ([]image.Image, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read icon: %w", err)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode icon: %w", err)
+	}
+
+	if format != "gif" {
+		return []image.Image{img}, nil
+	}
+
+	g, err := gif.DecodeAll(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode icon: %w", err)
+	}
+
+	// Frames after the first can only cover what changed, so draw each
+	// one over the previous
+	canvas := image.NewNRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	frames := make([]image.Image, 0, len(g.Image))
+
+	for _, frame := range g.Image {
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		img := image.NewNRGBA(canvas.Rect)
+		copy(img.Pix, canvas.Pix)
+
+		frames = append(frames, img)
+	}
+
+	return frames, nil
+}
@@ -0,0 +1,248 @@
+package psx
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// An IndexedSave describes a file found by Index.
+type IndexedSave struct {
+	Name   string
+	Serial string
+	Title  string
+	Size   int64
+	// Hash is the hash of the file contents, see File.Hash.
+	Hash [sha256.Size]byte
+}
+
+// An IndexResult describes a memory card found by Index, or an error
+// encountered when reading it.
+type IndexResult struct {
+	// Path is the slash-separated path of the memory card image.
+	Path string
+	// Page is the position of the memory card within a multi-card image,
+	// counting from one.
+	Page     int
+	Format   Format
+	Modified time.Time
+	Saves    []IndexedSave
+	Err      error
+}
+
+type indexJob struct {
+	name string
+	err  error
+}
+
+func indexReader(r *Reader) ([]IndexedSave, error) {
+	saves := make([]IndexedSave, 0, len(r.File))
+
+	for _, f := range r.File {
+		title, err := f.Title()
+		if err != nil {
+			return nil, &FileError{Name: f.Name, Err: err}
+		}
+
+		sum, err := f.Hash()
+		if err != nil {
+			return nil, err
+		}
+
+		saves = append(saves, IndexedSave{
+			Name:   f.Name,
+			Serial: f.Serial(),
+			Title:  title,
+			Size:   f.Size,
+			Hash:   sum,
+		})
+	}
+
+	return saves, nil
+}
+
+// readers returns a Reader for each memory card within the memory card image
+// read by ra, or nil if it's not a memory card image.
+func readers(ra io.ReaderAt, size int64) ([]*Reader, error) {
+	f, ok, err := DetectFormat(ra, size)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	if f == FormatSRM {
+		cr, err := NewContainerReader(io.NewSectionReader(ra, 0, size))
+		if err != nil {
+			return nil, err
+		}
+
+		return cr.Page, nil
+	}
+
+	r, err := NewReaderAt(ra, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*Reader{r}, nil
+}
+
+func indexFile(fsys fs.FS, name string) []*IndexResult {
+	failed := func(err error) []*IndexResult {
+		return []*IndexResult{{Path: name, Err: err}}
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return failed(err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return failed(err)
+	}
+
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			return failed(err)
+		}
+
+		ra = bytes.NewReader(b)
+	}
+
+	rs, err := readers(ra, fi.Size())
+	if err != nil {
+		return failed(err)
+	}
+
+	results := make([]*IndexResult, 0, len(rs))
+
+	for i, r := range rs {
+		result := &IndexResult{
+			Path:     name,
+			Page:     i + 1,
+			Format:   r.Format(),
+			Modified: fi.ModTime(),
+		}
+
+		result.Saves, result.Err = indexReader(r)
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// Index walks fsys looking for memory card images in any supported format,
+// including multi-card images, and reads them using up to workers goroutines,
+// or runtime.GOMAXPROCS if workers is less than one. Any other files are
+// ignored. A directory tree can be indexed with os.DirFS.
+//
+// fn is called with each memory card found, or with any error encountered
+// reading a file or directory, in no particular order. Calls to fn aren't
+// made concurrently. If fn returns an error, Index stops and returns it. If
+// ctx is cancelled, Index stops and returns the context error.
+func Index(ctx context.Context, fsys fs.FS, workers int, fn func(*IndexResult) error) error {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		jobs    = make(chan indexJob)
+		results = make(chan []*IndexResult)
+	)
+
+	go func() {
+		defer close(jobs)
+
+		_ = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+			if err == nil && !d.Type().IsRegular() {
+				return nil
+			}
+
+			select {
+			case jobs <- indexJob{name, err}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			if err != nil && d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+
+			return nil
+		})
+	}()
+
+	wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for job := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				var r []*IndexResult
+
+				if job.err != nil {
+					r = []*IndexResult{{Path: job.name, Err: job.err}}
+				} else {
+					r = indexFile(fsys, job.name)
+				}
+
+				if len(r) == 0 {
+					continue
+				}
+
+				select {
+				case results <- r:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var err error
+
+	for r := range results {
+		for _, result := range r {
+			if err != nil {
+				break
+			}
+
+			if err = fn(result); err != nil {
+				cancel()
+			}
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("unable to index: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package psx_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
+)
+
+//nolint:funlen
+func TestIndex(t *testing.T) {
+	t.Parallel()
+
+	m1, err := os.ReadFile(filepath.Join("testdata", "m1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mc2, err := os.ReadFile(filepath.Join("testdata", "MemoryCard2-1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gme := new(bytes.Buffer)
+	if err := psx.Convert(gme, psx.FormatDexDrive, bytes.NewReader(mc2)); err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := append([]byte{}, m1...)
+	corrupt[frameSize] ^= 0xff
+
+	fsys := fstest.MapFS{
+		"m1.mcd":          {Data: m1},
+		"sub/mc2.gme":     {Data: gme.Bytes()},
+		"sub/game.srm":    {Data: bytes.Join([][]byte{m1, mc2}, nil)},
+		"sub/corrupt.mcd": {Data: corrupt},
+		"README.txt":      {Data: []byte("not a memory card")},
+	}
+
+	var results []*psx.IndexResult
+
+	if err := psx.Index(context.Background(), fsys, 2, func(r *psx.IndexResult) error {
+		results = append(results, r)
+
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Path == results[j].Path {
+			return results[i].Page < results[j].Page
+		}
+
+		return results[i].Path < results[j].Path
+	})
+
+	if !assert.Len(t, results, 5) {
+		return
+	}
+
+	assert.Equal(t, "m1.mcd", results[0].Path)
+	assert.Equal(t, psx.FormatRaw, results[0].Format)
+	assert.Len(t, results[0].Saves, 10)
+
+	assert.Equal(t, "sub/corrupt.mcd", results[1].Path)
+	assert.ErrorIs(t, results[1].Err, psx.ErrBadDirectoryChecksum)
+
+	assert.Equal(t, "sub/game.srm", results[2].Path)
+	assert.Equal(t, 1, results[2].Page)
+	assert.Len(t, results[2].Saves, 10)
+	assert.Equal(t, "sub/game.srm", results[3].Path)
+	assert.Equal(t, 2, results[3].Page)
+	assert.Len(t, results[3].Saves, 10)
+
+	assert.Equal(t, "sub/mc2.gme", results[4].Path)
+	assert.Equal(t, psx.FormatDexDrive, results[4].Format)
+
+	for _, save := range results[4].Saves {
+		if save.Name != "BESLES-00024TOMBRAID" {
+			continue
+		}
+
+		assert.Equal(t, "SLES-00024", save.Serial)
+		assert.Equal(t, "Tomb Raider", save.Title)
+		assert.NotEqual(t, [sha256.Size]byte{}, save.Hash)
+	}
+
+	errStop := errors.New("stop")
+
+	assert.ErrorIs(t, psx.Index(context.Background(), fsys, 0, func(*psx.IndexResult) error {
+		return errStop
+	}), errStop)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, psx.Index(ctx, fsys, 0, func(*psx.IndexResult) error {
+		return nil
+	}), context.Canceled)
+}
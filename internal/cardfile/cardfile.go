@@ -0,0 +1,145 @@
+// Package cardfile updates the files on memory card images stored on disk.
+// Each file is handled in the MCS format, which is the directory frame of the
+// file followed by its blocks.
+package cardfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/bodgit/psx"
+)
+
+// Read returns the named file on the memory card read by r as a file of its
+// own.
+func Read(r *psx.Reader, name string) ([]byte, error) {
+	f, err := r.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", name, err)
+	}
+
+	return psx.ExportSave(b), nil
+}
+
+// put writes the file in b to fw and closes it.
+func put(fw io.WriteCloser, b []byte) error {
+	if _, err := fw.Write(b); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return fw.Close() //nolint:wrapcheck
+}
+
+// Write adds the file in b to the memory card written by w.
+func Write(w *psx.Writer, b []byte) error {
+	fw, err := w.Create()
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return put(fw, b)
+}
+
+// Abort abandons any changes made with w.
+func Abort(w *psx.Writer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_ = w.CloseContext(ctx)
+}
+
+// edit calls fn with a Writer updating the memory card image in the named
+// file, which is only replaced if fn succeeds. Everything else on the memory
+// card is kept as-is, see psx.OpenForUpdate.
+func edit(name string, fn func(*psx.Writer) error) error {
+	w, err := psx.OpenForUpdate(name)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if err := fn(w); err != nil {
+		Abort(w)
+
+		return err
+	}
+
+	return w.Close() //nolint:wrapcheck
+}
+
+// Add validates the file in b with psx.ValidateSave and adds it to the memory card image in the
+// named file.
+func Add(name string, b []byte) error {
+	if err := psx.ValidateSave(b); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return edit(name, func(w *psx.Writer) error {
+		return Write(w, b)
+	})
+}
+
+// Put is like Add but any file with the same name is replaced.
+func Put(name string, b []byte) error {
+	if err := psx.ValidateSave(b); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return edit(name, func(w *psx.Writer) error {
+		fw, err := w.Replace(psx.SaveName(b))
+		if errors.Is(err, fs.ErrNotExist) {
+			return Write(w, b)
+		}
+
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return put(fw, b)
+	})
+}
+
+// Remove removes the file named save from the memory card image in the named
+// file.
+func Remove(name, save string) error {
+	return edit(name, func(w *psx.Writer) error {
+		return w.Remove(save) //nolint:wrapcheck
+	})
+}
+
+// Move renames the file named save on the memory card image in the named
+// file to newSave, see psx.RenameSave.
+func Move(name, save, newSave string) error {
+	rc, err := psx.OpenReader(name)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	b, err := Read(&rc.Reader, save)
+	rc.Close()
+
+	if err != nil {
+		return err
+	}
+
+	if err := psx.RenameSave(b, newSave); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return edit(name, func(w *psx.Writer) error {
+		fw, err := w.Replace(save)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return put(fw, b)
+	})
+}
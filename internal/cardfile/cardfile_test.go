@@ -0,0 +1,277 @@
+package cardfile_test
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/bodgit/psx/internal/cardfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func copyCard(t *testing.T, src string, prefix ...[]byte) string {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join("..", "..", "testdata", src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(t.TempDir(), src)
+	if err := os.WriteFile(dst, append(bytes.Join(prefix, nil), b...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return dst
+}
+
+func saves(t *testing.T, name string) []string {
+	t.Helper()
+
+	rc, err := psx.OpenReader(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	names := make([]string, 0, len(rc.File))
+	for _, f := range rc.File {
+		names = append(names, f.Name)
+	}
+
+	return names
+}
+
+func readSave(t *testing.T, name, save string) []byte {
+	t.Helper()
+
+	rc, err := psx.OpenReader(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	b, err := cardfile.Read(&rc.Reader, save)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestRemove(t *testing.T) {
+	t.Parallel()
+
+	tables := map[string]struct {
+		save string
+		err  error
+	}{
+		"found": {
+			save: "BASLUS-00603-DASH00",
+		},
+		"not found": {
+			save: "BASLUS-00603-MISSING",
+			err:  fs.ErrNotExist,
+		},
+	}
+
+	for name, table := range tables {
+		name, table := name, table
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			card := copyCard(t, "dirty.mcr")
+			before := saves(t, card)
+
+			err := cardfile.Remove(card, table.save)
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+				assert.Equal(t, before, saves(t, card))
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Len(t, saves(t, card), len(before)-1)
+			assert.NotContains(t, saves(t, card), table.save)
+		})
+	}
+}
+
+// Editing a memory card only changes the directory frames.
+func TestRemoveKeepsHeaderBlock(t *testing.T) {
+	t.Parallel()
+
+	card := copyCard(t, "dirty.mcr")
+
+	before, err := os.ReadFile(card)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cardfile.Remove(card, "BASLUS-00603-DASH00"); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := os.ReadFile(card)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, before[:128], after[:128])
+	assert.Equal(t, before[16*128:], after[16*128:])
+}
+
+func TestMove(t *testing.T) {
+	t.Parallel()
+
+	tables := map[string]struct {
+		card, save, newSave string
+		err                 error
+	}{
+		"rename": {
+			card:    "m1.mcd",
+			save:    "BASLUS-00603-DASH00",
+			newSave: "BASLUS-00603-OTHER",
+		},
+		"same name": {
+			card:    "m1.mcd",
+			save:    "BASLUS-00603-DASH00",
+			newSave: "BASLUS-00603-DASH00",
+		},
+		"not found": {
+			card:    "m1.mcd",
+			save:    "BASLUS-00603-MISSING",
+			newSave: "BASLUS-00603-OTHER",
+			err:     fs.ErrNotExist,
+		},
+		"duplicate": {
+			card:    "MemoryCard2-1.mcd",
+			save:    "BESCES-00984GT",
+			newSave: "BESCES-00984RT",
+			err:     psx.ErrDuplicateName,
+		},
+		"bad name": {
+			card:    "m1.mcd",
+			save:    "BASLUS-00603-DASH00",
+			newSave: "BESLES-00603-DASH00",
+			err:     psx.ErrBadName,
+		},
+	}
+
+	for name, table := range tables {
+		name, table := name, table
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			card := copyCard(t, table.card)
+			before := saves(t, card)
+
+			err := cardfile.Move(card, table.save, table.newSave)
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+				assert.Equal(t, before, saves(t, card))
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Len(t, saves(t, card), len(before))
+			assert.Contains(t, saves(t, card), table.newSave)
+		})
+	}
+}
+
+func TestPut(t *testing.T) {
+	t.Parallel()
+
+	card := copyCard(t, "m1.mcd")
+	save := readSave(t, card, "BASLUS-00603-DASH00")
+
+	// The same name replaces the file
+	if err := cardfile.Put(card, save); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, saves(t, card), 10)
+
+	if err := psx.RenameSave(save, "BASLUS-00603-OTHER"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A different name adds it
+	if err := cardfile.Put(card, save); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, saves(t, card), 11)
+	assert.Equal(t, save, readSave(t, card, "BASLUS-00603-OTHER"))
+
+	assert.ErrorIs(t, cardfile.Put(card, save[:128]), psx.ErrInvalidLength)
+}
+
+// Anything around the memory card in an image using psx.FormatSRM is kept.
+func TestSRM(t *testing.T) {
+	t.Parallel()
+
+	save := readSave(t, copyCard(t, "m1.mcd"), "BASLUS-00603-DASH00")
+	if err := psx.RenameSave(save, "BASLUS-00603-OTHER"); err != nil {
+		t.Fatal(err)
+	}
+
+	tables := map[string]func(string) error{
+		"add": func(name string) error {
+			return cardfile.Add(name, save)
+		},
+		"put": func(name string) error {
+			return cardfile.Put(name, save)
+		},
+		"remove": func(name string) error {
+			return cardfile.Remove(name, "BASLUS-00603-DASH00")
+		},
+		"move": func(name string) error {
+			return cardfile.Move(name, "BASLUS-00603-DASH00", "BASLUS-00603-OTHER")
+		},
+	}
+
+	for name, fn := range tables {
+		name, fn := name, fn
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			prefix, suffix := bytes.Repeat([]byte{0xa5}, 0x200), bytes.Repeat([]byte{0x5a}, 0x80)
+			card := copyCard(t, "m1.mcd", prefix)
+
+			f, err := os.OpenFile(card, os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := f.Write(suffix); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.NoError(t, fn(card))
+
+			b, err := os.ReadFile(card)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, prefix, b[:len(prefix)])
+			assert.Equal(t, suffix, b[len(b)-len(suffix):])
+
+			format, ok, err := psx.DetectFormat(bytes.NewReader(b), int64(len(b)))
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, psx.FormatSRM, format)
+		})
+	}
+}
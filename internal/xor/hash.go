@@ -41,3 +41,14 @@ func New() hash.Hash {
 
 	return d
 }
+
+// Checksum returns the XOR of every byte in b.
+func Checksum(b []byte) byte {
+	var sum byte
+
+	for _, x := range b {
+		sum ^= x
+	}
+
+	return sum
+}
@@ -27,3 +27,10 @@ func TestHash(t *testing.T) {
 
 	assert.Equal(t, []byte{0x00}, h.Sum(nil))
 }
+
+func TestChecksum(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, byte(0x0e), xor.Checksum(append([]byte{'M', 'C'}, bytes.Repeat([]byte{0}, 125)...)))
+	assert.Equal(t, byte(0x00), xor.Checksum(nil))
+}
@@ -3,7 +3,6 @@ package psx
 import (
 	"bytes"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"io"
 )
@@ -19,53 +18,84 @@ const (
 	blockUnavailable = 0xff
 )
 
+const (
+	blockDeletedFirstLink byte = iota + 0xa1
+	blockDeletedMiddleLink
+	blockDeletedLastLink
+)
+
 const (
 	lastLink        = 0xffff
 	blockSize       = 0x2000
 	numBlocks       = 15
 	reservedBlocks  = 1
 	numUnusedFrames = 20
-	frameSize       = 128
-	cardSize        = blockSize * (numBlocks + reservedBlocks)
+	// numReservedFrames is the number of frames between the unused frames
+	// and the trailing frame, the first 20 of which hold the replacement
+	// data for any broken sectors
+	numReservedFrames = 27
+	frameSize         = 128
+	cardSize          = blockSize * (numBlocks + reservedBlocks)
 )
 
-var (
-	dataSignature = [2]byte{'S', 'C'} //nolint:deadcode,gochecknoglobals,unused,varcheck
-
-	errBadDataSignature = errors.New("bad data block signature") //nolint:deadcode,unused,varcheck
-	errTrailingBytes    = errors.New("trailing bytes")
+// Sizes of the files on a memory card.
+const (
+	// BlockSize is the size of each block used by a file.
+	BlockSize = blockSize
+	// NumBlocks is the number of blocks available for files.
+	NumBlocks = numBlocks
+	// MaxSaveSize is the size of the largest file as read with File.Open,
+	// which is its directory frame followed by every block.
+	MaxSaveSize = frameSize + numBlocks*blockSize
 )
 
+var dataSignature = [2]byte{'S', 'C'} //nolint:gochecknoglobals
+
 type headerBlock struct {
 	HeaderFrame    headerFrame
 	DirectoryFrame [numBlocks]directoryFrame
 	UnusedFrame    [numUnusedFrames]unusedFrame
-	_              [27 * frameSize]byte
+	Reserved       [numReservedFrames * frameSize]byte
 	TrailingFrame  headerFrame
 }
 
 func (hb *headerBlock) unmarshalBinary(r io.Reader) error {
-	if err := hb.HeaderFrame.unmarshalBinary(r); err != nil {
+	b := make([]byte, blockSize)
+	if _, err := io.ReadFull(r, b); err != nil {
 		return err
 	}
 
+	return hb.decode(b)
+}
+
+// decode decodes the header block b without allocating, unless there's an
+// error.
+func (hb *headerBlock) decode(b []byte) error {
+	frame := func(i int) []byte {
+		return b[i*frameSize : (i+1)*frameSize]
+	}
+
+	if err := hb.HeaderFrame.decode(frame(0)); err != nil {
+		return locate(err, 0, -1)
+	}
+
 	for i := 0; i < numBlocks; i++ {
-		if err := hb.DirectoryFrame[i].unmarshalBinary(r); err != nil {
-			return err
+		if err := hb.DirectoryFrame[i].decode(frame(firstDirectoryFrame + i)); err != nil {
+			return locate(err, firstDirectoryFrame+i, i)
 		}
 	}
 
 	for i := 0; i < numUnusedFrames; i++ {
-		if err := binary.Read(r, binary.LittleEndian, &hb.UnusedFrame[i]); err != nil {
-			return err
-		}
+		hb.UnusedFrame[i].decode(frame(firstUnusedFrame + i))
 	}
 
-	if _, err := io.CopyN(io.Discard, r, 27*frameSize); err != nil {
-		return err
+	copy(hb.Reserved[:], b[(firstUnusedFrame+numUnusedFrames)*frameSize:trailingFrame*frameSize])
+
+	if err := hb.TrailingFrame.decode(frame(trailingFrame)); err != nil {
+		return locate(err, trailingFrame, -1)
 	}
 
-	return hb.TrailingFrame.unmarshalBinary(r)
+	return nil
 }
 
 type memoryCard struct {
@@ -73,15 +103,11 @@ type memoryCard struct {
 	DataBlock   [numBlocks][blockSize]byte
 }
 
-func (mc *memoryCard) size() int {
-	return cardSize
-}
-
-func (mc *memoryCard) count() int {
+func (hb *headerBlock) count() int {
 	count := 0
 
 	for i := 0; i < numBlocks; i++ {
-		if !mc.HeaderBlock.DirectoryFrame[i].isFirst() {
+		if !hb.DirectoryFrame[i].isFirst() {
 			continue
 		}
 
@@ -91,6 +117,35 @@ func (mc *memoryCard) count() int {
 	return count
 }
 
+// find returns the index of the first block of the named file.
+func (hb *headerBlock) find(name string) (int, bool) {
+	for i := range hb.DirectoryFrame {
+		if hb.DirectoryFrame[i].isFirst() && hb.DirectoryFrame[i].filename() == name {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// free returns the indices of the blocks that can be used for a new file.
+// Blocks that have never been used come first, followed by the blocks of any
+// deleted files.
+func (hb *headerBlock) free() []int {
+	var free, deleted []int
+
+	for i := range hb.DirectoryFrame {
+		switch ab := hb.DirectoryFrame[i].AvailableBlocks; {
+		case ab == blockAvailable:
+			free = append(free, i)
+		case ab >= blockDeletedFirstLink && ab <= blockDeletedLastLink:
+			deleted = append(deleted, i)
+		}
+	}
+
+	return append(free, deleted...)
+}
+
 func (mc *memoryCard) checksum() error {
 	if err := mc.HeaderBlock.HeaderFrame.checksum(); err != nil {
 		return err
@@ -115,7 +170,7 @@ func (mc *memoryCard) unmarshalBinary(r io.Reader) error {
 	}
 
 	if n, _ := io.CopyN(io.Discard, r, 1); n > 0 {
-		return errTrailingBytes
+		return ErrTrailingBytes
 	}
 
 	return nil
@@ -158,20 +213,10 @@ func newMemoryCard() (*memoryCard, error) {
 }
 
 // DetectMemoryCard works out if the io.ReaderAt r pointing to the data of size
-// bytes looks sufficiently like a PlayStation 1 memory card image.
+// bytes looks sufficiently like a PlayStation 1 memory card image in any of
+// the supported formats.
 func DetectMemoryCard(r io.ReaderAt, size int64) (bool, error) {
-	if size == cardSize {
-		sr := io.NewSectionReader(r, 0, int64(len(headerSignature)))
-
-		b, err := io.ReadAll(sr)
-		if err != nil {
-			return false, fmt.Errorf("unable to read header signature: %w", err)
-		}
-
-		if bytes.Equal(b, headerSignature[:]) {
-			return true, nil
-		}
-	}
+	_, ok, err := DetectFormat(r, size)
 
-	return false, nil
+	return ok, err
 }
@@ -0,0 +1,304 @@
+package psx
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// A ConflictPolicy decides which file is kept when more than one memory card
+// being merged contains a file with the same name.
+type ConflictPolicy int
+
+const (
+	// KeepFirst keeps the file from the first memory card it appears on.
+	KeepFirst ConflictPolicy = iota
+	// KeepNewest keeps the file from the memory card with the latest
+	// modification time.
+	KeepNewest
+	// KeepLargest keeps the largest file.
+	KeepLargest
+	// KeepBoth keeps every distinct file, renaming all but the first by
+	// altering the identifier part of the name. A game won't find a
+	// renamed file until it's renamed back.
+	KeepBoth
+)
+
+func readFile(f *File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", f.Name, err)
+	}
+
+	return b, nil
+}
+
+// rename gives the file in b a name not present in taken by appending a
+// number to the identifier, truncating it if necessary.
+func rename(b []byte, taken map[string]struct{}) {
+	prefix := cstring(b[filenameOffset:identifierOffset])
+	identifier := cstring(b[identifierOffset : filenameOffset+filenameSize])
+
+	for n := 1; ; n++ {
+		suffix := strconv.Itoa(n)
+
+		id := identifier
+		if limit := filenameOffset + filenameSize - identifierOffset - len(suffix); len(id) > limit {
+			id = id[:limit]
+		}
+
+		if _, ok := taken[prefix+id+suffix]; ok {
+			continue
+		}
+
+		taken[prefix+id+suffix] = struct{}{}
+
+		field := b[identifierOffset : filenameOffset+filenameSize]
+		copy(field, make([]byte, len(field)))
+		copy(field, id+suffix)
+
+		return
+	}
+}
+
+// MergeOptions control how Merge decides between files with the same name.
+type MergeOptions struct {
+	// Policy decides which file is kept.
+	Policy ConflictPolicy
+	// Modified is only used by KeepNewest and may be nil otherwise. It's
+	// called with the index of each Reader passed to Merge and should
+	// return when that memory card was last modified, typically the
+	// modification time of the image, as memory cards don't record when
+	// a file was saved.
+	Modified func(int) time.Time
+}
+
+type candidate struct {
+	f        *File
+	modified time.Time
+}
+
+func better(policy ConflictPolicy, a, b candidate) bool {
+	switch policy {
+	case KeepNewest:
+		return b.modified.After(a.modified)
+	case KeepLargest:
+		return b.f.Size > a.f.Size
+	case KeepFirst, KeepBoth:
+	}
+
+	return false
+}
+
+func resolveAll(rs []*Reader) ([][]byte, error) {
+	var (
+		files [][]byte
+		taken = make(map[string]struct{})
+		seen  = make(map[string][][sha256.Size]byte)
+	)
+
+	for _, r := range rs {
+		for _, f := range r.File {
+			taken[f.Name] = struct{}{}
+		}
+	}
+
+	for _, r := range rs {
+	next:
+		for _, f := range r.File {
+			sum, err := f.Hash()
+			if err != nil {
+				return nil, err
+			}
+
+			// Don't keep identical copies of the same file
+			for _, other := range seen[f.Name] {
+				if other == sum {
+					continue next
+				}
+			}
+
+			b, err := readFile(f)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(seen[f.Name]) > 0 {
+				rename(b, taken)
+			}
+
+			seen[f.Name] = append(seen[f.Name], sum)
+			files = append(files, b)
+		}
+	}
+
+	return files, nil
+}
+
+func resolve(opts MergeOptions, rs []*Reader) ([][]byte, error) {
+	if opts.Policy == KeepBoth {
+		return resolveAll(rs)
+	}
+
+	var (
+		names  []string
+		chosen = make(map[string]candidate)
+	)
+
+	for i, r := range rs {
+		var t time.Time
+		if opts.Policy == KeepNewest && opts.Modified != nil {
+			t = opts.Modified(i)
+		}
+
+		for _, f := range r.File {
+			if c, ok := chosen[f.Name]; !ok {
+				names = append(names, f.Name)
+				chosen[f.Name] = candidate{f, t}
+			} else if better(opts.Policy, c, candidate{f, t}) {
+				chosen[f.Name] = candidate{f, t}
+			}
+		}
+	}
+
+	files := make([][]byte, 0, len(names))
+
+	for _, name := range names {
+		b, err := readFile(chosen[name].f)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, b)
+	}
+
+	return files, nil
+}
+
+func (w *Writer) add(b []byte) error {
+	fw, err := w.Create()
+	if err != nil {
+		return err
+	}
+
+	if _, err := fw.Write(b); err != nil {
+		return fmt.Errorf("unable to write: %w", err)
+	}
+
+	return fw.Close()
+}
+
+// place adds the file in b to the first Writer in ws with enough free space.
+func place(ws []*Writer, b []byte) (bool, error) {
+	for _, w := range ws {
+		err := w.add(b)
+		if err == nil {
+			return true, nil
+		}
+
+		if !errors.Is(err, ErrNoFreeSpace) {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// abortAll abandons every Writer in ws.
+func abortAll(ws []*Writer) {
+	for _, w := range ws {
+		w.abort()
+	}
+}
+
+// closeAll closes every Writer in ws, abandoning the rest if one fails.
+func closeAll(ws []*Writer) error {
+	for i, w := range ws {
+		if err := w.Close(); err != nil {
+			abortAll(ws[i+1:])
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Merge copies the files from the memory cards read by rs onto one or more
+// new memory cards, using opts to decide between files with the same name.
+// Each time another memory card is needed, create is called with the number
+// of memory cards created so far and should return a new Writer. Files are
+// placed on the first memory card with enough free space. On success, each
+// Writer is closed and the number of memory cards created is returned,
+// otherwise each Writer is abandoned as with a cancelled CloseContext.
+func Merge(create func(int) (*Writer, error), opts MergeOptions, rs ...*Reader) (int, error) {
+	files, err := resolve(opts, rs)
+	if err != nil {
+		return 0, err
+	}
+
+	var ws []*Writer
+
+	if err := mergeFiles(func(i int) (*Writer, error) {
+		w, err := create(i)
+		if err == nil {
+			ws = append(ws, w)
+		}
+
+		return w, err
+	}, files); err != nil {
+		abortAll(ws)
+
+		return 0, err
+	}
+
+	if err := closeAll(ws); err != nil {
+		return 0, err
+	}
+
+	return len(ws), nil
+}
+
+// mergeFiles places each file in files onto the first Writer with enough free
+// space, calling create to add another Writer as needed.
+func mergeFiles(create func(int) (*Writer, error), files [][]byte) error {
+	w, err := create(0)
+	if err != nil {
+		return err
+	}
+
+	ws := []*Writer{w}
+
+	for _, f := range files {
+		ok, err := place(ws, f)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			continue
+		}
+
+		w, err := create(len(ws))
+		if err != nil {
+			return err
+		}
+
+		ws = append(ws, w)
+
+		if err := w.add(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+package psx_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
+)
+
+func openReaders(t *testing.T, files ...[]byte) []*psx.Reader {
+	t.Helper()
+
+	rs := make([]*psx.Reader, 0, len(files))
+
+	for _, b := range files {
+		r, err := psx.NewReader(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rs = append(rs, r)
+	}
+
+	return rs
+}
+
+func fileNames(t *testing.T, bufs []*bytes.Buffer) []string {
+	t.Helper()
+
+	var names []string
+
+	for _, buf := range bufs {
+		r, err := psx.NewReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, f := range r.File {
+			names = append(names, f.Name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+//nolint:funlen
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	m1, err := os.ReadFile(filepath.Join("testdata", "m1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mc2, err := os.ReadFile(filepath.Join("testdata", "MemoryCard2-1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modified := append([]byte{}, m1...)
+	modified[2*blockSize-1] ^= 0xff
+
+	tables := []struct {
+		name   string
+		policy psx.ConflictPolicy
+		files  [][]byte
+		cards  int
+		names  int
+	}{
+		{
+			name:   "spill",
+			policy: psx.KeepFirst,
+			files:  [][]byte{m1, mc2},
+			cards:  2,
+			names:  20,
+		},
+		{
+			name:   "identical",
+			policy: psx.KeepBoth,
+			files:  [][]byte{m1, m1},
+			cards:  1,
+			names:  10,
+		},
+		{
+			name:   "keep both",
+			policy: psx.KeepBoth,
+			files:  [][]byte{m1, modified},
+			cards:  1,
+			names:  11,
+		},
+		{
+			name:   "keep first",
+			policy: psx.KeepFirst,
+			files:  [][]byte{m1, modified},
+			cards:  1,
+			names:  10,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			var bufs []*bytes.Buffer
+
+			n, err := psx.Merge(func(int) (*psx.Writer, error) {
+				buf := new(bytes.Buffer)
+				bufs = append(bufs, buf)
+
+				return psx.NewWriter(buf)
+			}, psx.MergeOptions{Policy: table.policy}, openReaders(t, table.files...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, table.cards, n)
+			assert.Len(t, bufs, table.cards)
+
+			names := fileNames(t, bufs)
+			assert.Len(t, names, table.names)
+
+			if table.name == "keep both" {
+				assert.Contains(t, names, "BISLPS-000931")
+			}
+		})
+	}
+}
+
+func TestMergeKeepNewest(t *testing.T) {
+	t.Parallel()
+
+	m1, err := os.ReadFile(filepath.Join("testdata", "m1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modified := append([]byte{}, m1...)
+	modified[2*blockSize-1] ^= 0xff
+
+	for newest := 0; newest < 2; newest++ {
+		newest := newest
+		t.Run(strconv.Itoa(newest), func(t *testing.T) {
+			t.Parallel()
+
+			rs := openReaders(t, m1, modified)
+			buf := new(bytes.Buffer)
+
+			n, err := psx.Merge(func(int) (*psx.Writer, error) {
+				return psx.NewWriter(buf)
+			}, psx.MergeOptions{
+				Policy: psx.KeepNewest,
+				Modified: func(i int) time.Time {
+					if i == newest {
+						return time.Unix(1, 0)
+					}
+
+					return time.Time{}
+				},
+			}, rs...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, 1, n)
+
+			r, err := psx.NewReader(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want, err := rs[newest].File[0].Hash()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := r.File[0].Hash()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+// A failure partway through leaves nothing behind.
+func TestMergeAbort(t *testing.T) {
+	t.Parallel()
+
+	m1, err := os.ReadFile(filepath.Join("testdata", "m1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mc2, err := os.ReadFile(filepath.Join("testdata", "MemoryCard2-1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	errCreate := errors.New("create failed")
+
+	// The files don't all fit on one memory card
+	_, err = psx.Merge(func(i int) (*psx.Writer, error) {
+		if i > 0 {
+			return nil, errCreate
+		}
+
+		return psx.CreateFile(filepath.Join(dir, "merged.mcd"), psx.FormatRaw)
+	}, psx.MergeOptions{}, openReaders(t, m1, mc2)...)
+	assert.ErrorIs(t, err, errCreate)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,272 @@
+package psx
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//nolint:gochecknoglobals
+var serialRegexp = regexp.MustCompile(`^([A-Z]{4})[^0-9A-Z]?([0-9]{3})\.?([0-9]{2})$`)
+
+// Serial returns the serial number of the game that created the file, in the
+// usual "SLUS-00594" form, derived from the product code. If the product code
+// doesn't look like a serial number it is returned in upper case with
+// anything other than letters, digits and hyphens removed, so the result is
+// always safe to use as a file name but may be empty.
+func (f *File) Serial() string {
+	code := strings.ToUpper(strings.TrimSpace(f.ProductCode))
+
+	if m := serialRegexp.FindStringSubmatch(code); m != nil {
+		return m[1] + "-" + m[2] + m[3]
+	}
+
+	return sanitizeSerial(code)
+}
+
+func sanitizeSerial(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+
+		return -1
+	}, strings.ToUpper(s))
+}
+
+func validSerial(s string) bool {
+	return s != "" && sanitizeSerial(s) == s
+}
+
+// GroupSerials returns a function suitable for use with Split that maps the
+// serial number of each disc of a multi-disc game to the first serial number
+// in each group. Any serial number not in a group is returned unaltered.
+func GroupSerials(groups ...[]string) func(string) string {
+	m := make(map[string]string)
+
+	for _, group := range groups {
+		for _, serial := range group {
+			m[serial] = group[0]
+		}
+	}
+
+	return func(serial string) string {
+		if s, ok := m[serial]; ok {
+			return s
+		}
+
+		return serial
+	}
+}
+
+// A Layout describes how per-game memory card images are arranged within a
+// directory.
+type Layout int
+
+const (
+	// LayoutMemCardPro is the layout used by the MemCard PRO, where each
+	// game has a directory containing up to eight memory card images, such
+	// as SLUS-00594/SLUS-00594-1.mcd.
+	LayoutMemCardPro Layout = iota
+	// LayoutDuckStation is the layout used by DuckStation for per-game
+	// memory cards, where each memory card image is named after the game,
+	// such as SLUS-00594_1.mcd.
+	LayoutDuckStation
+)
+
+//nolint:gochecknoglobals
+var layoutRegexp = map[Layout]*regexp.Regexp{
+	LayoutMemCardPro:  regexp.MustCompile(`^(?:.*/)?([^/]+)/([^/]+)-([0-9]+)\.mcd$`),
+	LayoutDuckStation: regexp.MustCompile(`^(?:.*/)?([^/]+)_([0-9]+)\.mcd$`),
+}
+
+// Path returns the slash-separated path of the nth memory card image, counting
+// from one, for the game with the given serial number.
+func (l Layout) Path(serial string, n int) string {
+	switch l {
+	case LayoutMemCardPro:
+		return path.Join(serial, fmt.Sprintf("%s-%d.mcd", serial, n))
+	case LayoutDuckStation:
+		return fmt.Sprintf("%s_%d.mcd", serial, n)
+	default:
+		return ""
+	}
+}
+
+// Parse is the inverse of Path, returning the serial number and the memory
+// card number from a slash-separated path.
+func (l Layout) Parse(name string) (string, int, bool) {
+	re, ok := layoutRegexp[l]
+	if !ok {
+		return "", 0, false
+	}
+
+	m := re.FindStringSubmatch(name)
+	if m == nil {
+		return "", 0, false
+	}
+
+	if l == LayoutMemCardPro && m[1] != m[2] {
+		return "", 0, false
+	}
+
+	n, err := strconv.Atoi(m[len(m)-1])
+	if err != nil || n < 1 {
+		return "", 0, false
+	}
+
+	return m[1], n, true
+}
+
+// Split copies each file on the memory card read by r onto a per-game memory
+// card. The serial number of each file is passed through group, if not nil,
+// which allows the files from each disc of a multi-disc game to be kept
+// together, see GroupSerials. Each time another memory card is needed for a
+// game, create is called with the serial number and the number of the memory
+// card, counting from one, and should return a new Writer. Each serial
+// number passed to create only contains upper case letters, digits and
+// hyphens, otherwise ErrBadSerial is returned. Each Writer is closed on
+// success and the serial numbers are returned in the order they were first
+// seen, otherwise each Writer is abandoned as with a cancelled CloseContext.
+func Split(r *Reader, group func(string) string, create func(string, int) (*Writer, error)) ([]string, error) {
+	var ws []*Writer
+
+	serials, err := splitFiles(r, group, func(serial string, n int) (*Writer, error) {
+		w, err := create(serial, n)
+		if err == nil {
+			ws = append(ws, w)
+		}
+
+		return w, err
+	})
+	if err != nil {
+		abortAll(ws)
+
+		return nil, err
+	}
+
+	if err := closeAll(ws); err != nil {
+		return nil, err
+	}
+
+	return serials, nil
+}
+
+// splitFiles does the work of Split, other than closing each Writer.
+//
+//nolint:cyclop
+func splitFiles(r *Reader, group func(string) string, create func(string, int) (*Writer, error)) ([]string, error) {
+	var serials []string
+
+	games := make(map[string][]*Writer)
+
+	for _, f := range r.File {
+		serial := f.Serial()
+		if serial == "" {
+			serial = sanitizeSerial(f.Name)
+		}
+
+		if group != nil {
+			serial = group(serial)
+		}
+
+		if !validSerial(serial) {
+			return nil, fmt.Errorf("%s: %w: %q", f.Name, ErrBadSerial, serial)
+		}
+
+		b, err := readFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		ws, ok := games[serial]
+		if !ok {
+			serials = append(serials, serial)
+		}
+
+		placed, err := place(ws, b)
+		if err != nil {
+			return nil, err
+		}
+
+		if placed {
+			continue
+		}
+
+		w, err := create(serial, len(ws)+1)
+		if err != nil {
+			return nil, err
+		}
+
+		games[serial] = append(ws, w)
+
+		if err := w.add(b); err != nil {
+			return nil, err
+		}
+	}
+
+	return serials, nil
+}
+
+// Gather is the inverse of Split, merging all of the per-game memory card
+// images found within fsys according to layout onto one or more memory
+// cards. The memory card images are merged in lexical order, and for
+// KeepNewest each memory card image's modification time is used, see Merge
+// for a description of create.
+func Gather(fsys fs.FS, layout Layout, create func(int) (*Writer, error), policy ConflictPolicy) (int, error) {
+	var (
+		rs       []*Reader
+		modified []time.Time
+	)
+
+	if err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		if _, _, ok := layout.Parse(name); !ok {
+			return nil
+		}
+
+		r, t, err := openFS(fsys, name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		rs = append(rs, r)
+		modified = append(modified, t)
+
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("unable to walk: %w", err)
+	}
+
+	return Merge(create, MergeOptions{
+		Policy:   policy,
+		Modified: func(i int) time.Time { return modified[i] },
+	}, rs...)
+}
+
+func openFS(fsys fs.FS, name string) (*Reader, time.Time, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("unable to open: %w", err)
+	}
+	defer f.Close()
+
+	r, err := NewReader(f)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("unable to stat: %w", err)
+	}
+
+	return r, fi.ModTime(), nil
+}
@@ -0,0 +1,248 @@
+package psx_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayout(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		layout psx.Layout
+		path   string
+	}{
+		{psx.LayoutMemCardPro, "SLUS-00594/SLUS-00594-2.mcd"},
+		{psx.LayoutDuckStation, "SLUS-00594_2.mcd"},
+	}
+
+	for _, table := range tables {
+		table := table
+		t.Run(table.path, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, table.path, table.layout.Path("SLUS-00594", 2))
+
+			serial, n, ok := table.layout.Parse(table.path)
+			assert.True(t, ok)
+			assert.Equal(t, "SLUS-00594", serial)
+			assert.Equal(t, 2, n)
+		})
+	}
+
+	_, _, ok := psx.LayoutMemCardPro.Parse("SLUS-00594/SCUS-94163-1.mcd")
+	assert.False(t, ok)
+}
+
+func TestSplit(t *testing.T) {
+	t.Parallel()
+
+	rc, err := psx.OpenReader(filepath.Join("testdata", "MemoryCard2-1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	fsys := fstest.MapFS{}
+	bufs := make(map[string]*bytes.Buffer)
+
+	// Pretend two of the games are different discs of the same game
+	group := psx.GroupSerials([]string{"SCES-00984", "SLES-00024"})
+
+	serials, err := psx.Split(&rc.Reader, group, func(serial string, n int) (*psx.Writer, error) {
+		buf := new(bytes.Buffer)
+		bufs[psx.LayoutMemCardPro.Path(serial, n)] = buf
+
+		return psx.NewWriter(buf)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, serials, 8)
+	assert.Len(t, bufs, 8)
+	assert.NotContains(t, serials, "SLES-00024")
+
+	for name, buf := range bufs {
+		fsys[name] = &fstest.MapFile{Data: buf.Bytes()}
+	}
+
+	var out []*bytes.Buffer
+
+	n, err := psx.Gather(fsys, psx.LayoutMemCardPro, func(int) (*psx.Writer, error) {
+		buf := new(bytes.Buffer)
+		out = append(out, buf)
+
+		return psx.NewWriter(buf)
+	}, psx.KeepFirst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, n)
+
+	r, err := psx.NewReader(bytes.NewReader(out[0].Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := psx.Diff(&rc.Reader, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, d.Files)
+}
+
+func TestSerial(t *testing.T) {
+	t.Parallel()
+
+	b, err := os.ReadFile(filepath.Join("testdata", "m1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := psx.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serials := make([]string, 0, len(r.File))
+	for _, f := range r.File {
+		serials = append(serials, f.Serial())
+	}
+
+	assert.Contains(t, serials, "SLPS-00093")
+	assert.Contains(t, serials, "SLUS-00594")
+}
+
+func newCard(t *testing.T, names ...string) *psx.Reader {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+
+	w, err := psx.NewWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range names {
+		fw, err := w.Create()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := fw.Write(newSave(name, 1)); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := fw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := psx.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func TestSerialSanitised(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		productCode, serial string
+	}{
+		{"SLUS-00594", "SLUS-00594"},
+		{"slus005.94", "SLUS-00594"},
+		{"../../../x", "X"},
+		{"PCSX/..\\ab", "PCSXAB"},
+		{"./ /", ""},
+	}
+
+	for _, table := range tables {
+		table := table
+		t.Run(table.productCode, func(t *testing.T) {
+			t.Parallel()
+
+			r := newCard(t, "BA"+table.productCode+strings.Repeat("\x00", 10-len(table.productCode))+"TEST")
+
+			if assert.Len(t, r.File, 1) {
+				assert.Equal(t, table.serial, r.File[0].Serial())
+			}
+		})
+	}
+}
+
+func TestSplitBadSerial(t *testing.T) {
+	t.Parallel()
+
+	r := newCard(t, "BA../../../xTEST", "BA./ /./ /./TEST")
+
+	var serials []string
+
+	got, err := psx.Split(r, nil, func(serial string, n int) (*psx.Writer, error) {
+		serials = append(serials, serial)
+
+		return psx.NewWriter(io.Discard)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second file has no usable product code so falls back to its name
+	assert.Equal(t, []string{"X", "BATEST"}, got)
+	assert.Equal(t, got, serials)
+
+	_, err = psx.Split(r, func(string) string { return "../X" }, func(string, int) (*psx.Writer, error) {
+		return psx.NewWriter(io.Discard)
+	})
+	assert.True(t, errors.Is(err, psx.ErrBadSerial))
+}
+
+// A failure partway through leaves nothing behind.
+func TestSplitAbort(t *testing.T) {
+	t.Parallel()
+
+	rc, err := psx.OpenReader(filepath.Join("testdata", "m1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	dir := t.TempDir()
+	errCreate := errors.New("create failed")
+
+	var n int
+
+	_, err = psx.Split(&rc.Reader, nil, func(serial string, _ int) (*psx.Writer, error) {
+		if n++; n > 2 {
+			return nil, errCreate
+		}
+
+		return psx.CreateFile(filepath.Join(dir, serial+".mcd"), psx.FormatRaw)
+	})
+	assert.ErrorIs(t, err, errCreate)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, entries)
+}
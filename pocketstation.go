@@ -0,0 +1,136 @@
+package psx
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+)
+
+// A PocketStation application extends the title frame with some extra
+// fields, and is followed by the monochrome icon and artwork frames after the
+// usual icon frames.
+const (
+	pocketStationIconsOffset      = 0x50
+	pocketStationSignatureOffset  = 0x52
+	pocketStationArtworkOffset    = 0x56
+	pocketStationEntrypointOffset = 0x58
+	lcdSize                       = 32
+	lcdFrameSize                  = lcdSize * lcdSize / 8
+)
+
+//nolint:gochecknoglobals
+var (
+	pocketStationSignatures = [...]string{"MCX0", "MCX1"}
+
+	lcdPalette = color.Palette{color.White, color.Black}
+)
+
+// A PocketStation describes the extended header of a file containing a
+// PocketStation application.
+type PocketStation struct {
+	// Signature is either "MCX0" or "MCX1".
+	Signature string
+	// Entrypoint is the address of the entry point of the application.
+	Entrypoint uint32
+	// Icon holds the frames of the animated icon shown on the LCD by the
+	// PocketStation memory card manager.
+	Icon []image.Image
+	// Artwork holds any further frames of artwork for the LCD.
+	Artwork []image.Image
+}
+
+func pocketStationSignature(b []byte) (string, bool) {
+	s := string(b[pocketStationSignatureOffset : pocketStationSignatureOffset+4])
+
+	for _, signature := range pocketStationSignatures {
+		if s == signature {
+			return s, true
+		}
+	}
+
+	return "", false
+}
+
+// lcdImage decodes a 32x32 monochrome frame. Each row is stored as a
+// little-endian 32-bit word with the leftmost pixel in the lowest bit, and a
+// set bit is a black pixel.
+func lcdImage(b []byte) image.Image {
+	img := image.NewPaletted(image.Rect(0, 0, lcdSize, lcdSize), lcdPalette)
+
+	for y := 0; y < lcdSize; y++ {
+		row := binary.LittleEndian.Uint32(b[y*4:])
+
+		for x := 0; x < lcdSize; x++ {
+			img.Pix[y*img.Stride+x] = uint8(row >> x & 1)
+		}
+	}
+
+	return img
+}
+
+func lcdImages(b []byte, n int) []image.Image {
+	images := make([]image.Image, n)
+	for i := range images {
+		images[i] = lcdImage(b[i*lcdFrameSize:])
+	}
+
+	return images
+}
+
+// IsPocketStation reports whether the file contains a PocketStation
+// application.
+func (f *File) IsPocketStation() bool {
+	b, err := f.r.block(f.i)
+	if err != nil {
+		return false
+	}
+
+	_, ok := pocketStationSignature(b)
+
+	return ok
+}
+
+// PocketStation parses the extended header of a file containing a
+// PocketStation application and decodes the monochrome LCD frames. If the
+// file isn't a PocketStation application, ErrNotPocketStation is returned.
+func (f *File) PocketStation() (*PocketStation, error) {
+	b, err := f.r.block(f.i)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, ok := pocketStationSignature(b)
+	if !ok {
+		return nil, &FileError{Name: f.Name, Err: ErrNotPocketStation}
+	}
+
+	ps := &PocketStation{
+		Signature:  signature,
+		Entrypoint: binary.LittleEndian.Uint32(b[pocketStationEntrypointOffset:]),
+	}
+
+	icons := int(binary.LittleEndian.Uint16(b[pocketStationIconsOffset:]))
+	artwork := int(b[pocketStationArtworkOffset])
+
+	// The monochrome frames could spill over into further blocks
+	data := make([]byte, 0, len(f.blocks())*blockSize)
+
+	for _, i := range f.blocks() {
+		block, err := f.r.block(i)
+		if err != nil {
+			return nil, err
+		}
+
+		data = append(data, block...)
+	}
+
+	offset := iconOffset + iconFrames(b[iconDisplayOffset])*iconFrameSize
+	if offset+(icons+artwork)*lcdFrameSize > len(data) {
+		return nil, &FileError{Name: f.Name, Err: ErrInvalidLength}
+	}
+
+	ps.Icon = lcdImages(data[offset:], icons)
+	ps.Artwork = lcdImages(data[offset+icons*lcdFrameSize:], artwork)
+
+	return ps, nil
+}
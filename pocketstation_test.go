@@ -0,0 +1,97 @@
+package psx_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image/color"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPocketStationSave(name string) []byte {
+	b := newSave(name, 1)
+	data := b[frameSize:]
+
+	data[2] = 0x11 // One icon frame
+	binary.LittleEndian.PutUint16(data[0x50:], 2)
+	copy(data[0x52:], "MCX1")
+	data[0x56] = 1
+	binary.LittleEndian.PutUint32(data[0x58:], 0x2000094)
+
+	// First icon frame has the top-left pixel set, the second has the
+	// bottom-right pixel set, and the artwork is solid
+	data[0x100] = 0x01
+	data[0x200-1] = 0x80
+
+	for i := 0x200; i < 0x280; i++ {
+		data[i] = 0xff
+	}
+
+	return b
+}
+
+func TestPocketStation(t *testing.T) {
+	t.Parallel()
+
+	buf := new(bytes.Buffer)
+
+	w, err := psx.NewWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, save := range [][]byte{newPocketStationSave("BESCPS-00000APP"), newSave("BESLES-00000SAVE", 1)} {
+		fw, err := w.Create()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := fw.Write(save); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := fw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := psx.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.Len(t, r.File, 2) {
+		return
+	}
+
+	assert.True(t, r.File[0].IsPocketStation())
+	assert.False(t, r.File[1].IsPocketStation())
+
+	_, err = r.File[1].PocketStation()
+	assert.True(t, errors.Is(err, psx.ErrNotPocketStation))
+
+	ps, err := r.File[0].PocketStation()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "MCX1", ps.Signature)
+	assert.Equal(t, uint32(0x2000094), ps.Entrypoint)
+
+	if assert.Len(t, ps.Icon, 2) && assert.Len(t, ps.Artwork, 1) {
+		black, white := color.Gray{}, color.Gray{Y: 0xff}
+
+		assert.Equal(t, black, color.GrayModel.Convert(ps.Icon[0].At(0, 0)))
+		assert.Equal(t, white, color.GrayModel.Convert(ps.Icon[0].At(31, 31)))
+		assert.Equal(t, white, color.GrayModel.Convert(ps.Icon[1].At(0, 0)))
+		assert.Equal(t, black, color.GrayModel.Convert(ps.Icon[1].At(31, 31)))
+		assert.Equal(t, black, color.GrayModel.Convert(ps.Artwork[0].At(16, 16)))
+	}
+}
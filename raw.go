@@ -0,0 +1,176 @@
+package psx
+
+import "fmt"
+
+// Allocation is the allocation state of a block as recorded in its directory
+// frame.
+type Allocation byte
+
+// Allocation states.
+const (
+	// AllocationFirst is the first block of a file.
+	AllocationFirst Allocation = 0x51
+	// AllocationMiddle is a block in the middle of a file.
+	AllocationMiddle Allocation = 0x52
+	// AllocationLast is the last block of a file with more than one block.
+	AllocationLast Allocation = 0x53
+	// AllocationFree is a block that has never been used.
+	AllocationFree Allocation = 0xa0
+	// AllocationDeletedFirst is the first block of a deleted file.
+	AllocationDeletedFirst Allocation = 0xa1
+	// AllocationDeletedMiddle is a block in the middle of a deleted file.
+	AllocationDeletedMiddle Allocation = 0xa2
+	// AllocationDeletedLast is the last block of a deleted file.
+	AllocationDeletedLast Allocation = 0xa3
+	// AllocationUnavailable is a block that can't be used.
+	AllocationUnavailable Allocation = 0xff
+)
+
+//nolint:gochecknoglobals
+var allocationNames = map[Allocation]string{
+	AllocationFirst:         "first",
+	AllocationMiddle:        "middle",
+	AllocationLast:          "last",
+	AllocationFree:          "free",
+	AllocationDeletedFirst:  "deleted first",
+	AllocationDeletedMiddle: "deleted middle",
+	AllocationDeletedLast:   "deleted last",
+	AllocationUnavailable:   "unavailable",
+}
+
+func (a Allocation) String() string {
+	if s, ok := allocationNames[a]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("Allocation(%#02x)", byte(a))
+}
+
+// InUse reports whether the block belongs to a file.
+func (a Allocation) InUse() bool {
+	return a >= AllocationFirst && a <= AllocationLast
+}
+
+// Deleted reports whether the block belonged to a file that has since been
+// deleted. The contents of the block are usually still intact.
+func (a Allocation) Deleted() bool {
+	return a >= AllocationDeletedFirst && a <= AllocationDeletedLast
+}
+
+// A DirectoryFrame is the decoded contents of the directory frame describing
+// a data block, whether or not the block is in use.
+type DirectoryFrame struct {
+	// Frame is the index of the directory frame within the header block.
+	Frame int
+	// Block is the index of the data block described by the frame.
+	Block      int
+	Allocation Allocation
+	// Reserved holds the three bytes following the allocation state,
+	// normally all zero.
+	Reserved    [3]byte
+	Size        uint32
+	LinkOrder   uint16
+	Name        string
+	CountryCode string
+	ProductCode string
+	Identifier  string
+	// Padding holds the unused bytes between the file name and the
+	// checksum, which aren't always zero.
+	Padding  [97]byte
+	Checksum byte
+}
+
+// DirectoryFrames returns the directory frame for each data block, including
+// those that are free or deleted.
+func (r *Reader) DirectoryFrames() []DirectoryFrame {
+	frames := make([]DirectoryFrame, 0, numBlocks)
+
+	for i := range r.hb.DirectoryFrame {
+		df := &r.hb.DirectoryFrame[i]
+
+		frames = append(frames, DirectoryFrame{
+			Frame:       firstDirectoryFrame + i,
+			Block:       i,
+			Allocation:  Allocation(df.AvailableBlocks),
+			Reserved:    df.Reserved,
+			Size:        df.Size,
+			LinkOrder:   df.LinkOrder,
+			Name:        df.filename(),
+			CountryCode: df.countryCode(),
+			ProductCode: df.productCode(),
+			Identifier:  df.identifier(),
+			Padding:     df.Padding,
+			Checksum:    df.Checksum[0],
+		})
+	}
+
+	return frames
+}
+
+// An UnusedFrame is an entry in the list of broken sectors kept in the
+// header block.
+type UnusedFrame struct {
+	// Frame is the index of the frame within the header block.
+	Frame int
+	// Sector is the number of the broken sector, only valid if Broken is
+	// true.
+	Sector uint32
+	Broken bool
+}
+
+// UnusedFrames returns the entries in the list of broken sectors.
+func (r *Reader) UnusedFrames() []UnusedFrame {
+	frames := make([]UnusedFrame, 0, numUnusedFrames)
+
+	for i := range r.hb.UnusedFrame {
+		sector, ok := r.hb.UnusedFrame[i].brokenSector()
+
+		frames = append(frames, UnusedFrame{
+			Frame:  firstUnusedFrame + i,
+			Sector: sector,
+			Broken: ok,
+		})
+	}
+
+	return frames
+}
+
+// HeaderBlock returns a copy of the raw header block.
+func (r *Reader) HeaderBlock() []byte {
+	return append([]byte(nil), r.header...)
+}
+
+// block reads data block i.
+func (r *Reader) block(i int) ([]byte, error) {
+	b := make([]byte, blockSize)
+	if _, err := r.ra.ReadAt(b, int64((reservedBlocks+i)*blockSize)); err != nil {
+		return nil, fmt.Errorf("unable to read block %d: %w", i, err)
+	}
+
+	return b, nil
+}
+
+// Block returns a copy of the raw data block i, counting from zero.
+func (r *Reader) Block(i int) ([]byte, error) {
+	if i < 0 || i >= numBlocks {
+		return nil, &FrameError{Frame: -1, Block: i, Err: ErrOutOfRange}
+	}
+
+	return r.block(i)
+}
+
+// Frame returns a copy of the raw 128 byte frame i, counting from zero at the
+// start of the memory card. The first 64 frames are the header block so their
+// indices match those used by FrameError.
+func (r *Reader) Frame(i int) ([]byte, error) {
+	if i < 0 || i >= cardSize/frameSize {
+		return nil, &FrameError{Frame: i, Block: -1, Err: ErrOutOfRange}
+	}
+
+	b := make([]byte, frameSize)
+	if _, err := r.ra.ReadAt(b, int64(i*frameSize)); err != nil {
+		return nil, fmt.Errorf("unable to read frame %d: %w", i, err)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,81 @@
+package psx_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRawAccess(t *testing.T) {
+	t.Parallel()
+
+	b, err := os.ReadFile(filepath.Join("testdata", "m1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Delete the first file and mark a broken sector
+	df := directoryFrame(b, 0)
+	df[0] = 0xa1
+	df[2] = 0x12
+	df[frameSize-2] = 0x34
+	fixChecksum(df)
+
+	binary.LittleEndian.PutUint32(b[16*frameSize:], 0x123)
+
+	r, err := psx.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := r.DirectoryFrames()
+	if assert.Len(t, frames, 15) {
+		assert.Equal(t, 1, frames[0].Frame)
+		assert.Equal(t, psx.AllocationDeletedFirst, frames[0].Allocation)
+		assert.True(t, frames[0].Allocation.Deleted())
+		assert.Equal(t, "deleted first", frames[0].Allocation.String())
+		assert.Equal(t, df[127], frames[0].Checksum)
+		assert.Equal(t, [3]byte{0, 0x12, 0}, frames[0].Reserved)
+		assert.Equal(t, byte(0x34), frames[0].Padding[len(frames[0].Padding)-1])
+		assert.Equal(t, [3]byte{}, frames[1].Reserved)
+		assert.Equal(t, directoryFrame(b, 1)[30:frameSize-1], frames[1].Padding[:])
+		assert.NotEmpty(t, frames[0].Name)
+		assert.True(t, frames[1].Allocation.InUse())
+	}
+
+	assert.Len(t, r.File, 9)
+
+	unused := r.UnusedFrames()
+	if assert.Len(t, unused, 20) {
+		assert.Equal(t, psx.UnusedFrame{Frame: 16, Sector: 0x123, Broken: true}, unused[0])
+		assert.False(t, unused[1].Broken)
+	}
+
+	assert.Equal(t, b[:blockSize], r.HeaderBlock())
+
+	block, err := r.Block(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, b[blockSize:2*blockSize], block)
+
+	frame, err := r.Frame(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, df, frame)
+
+	_, err = r.Block(15)
+	assert.True(t, errors.Is(err, psx.ErrOutOfRange))
+
+	_, err = r.Frame(-1)
+	assert.True(t, errors.Is(err, psx.ErrOutOfRange))
+}
@@ -30,24 +30,49 @@ type File struct {
 	ProductCode string
 	Identifier  string
 
-	r *Reader
-	i int
+	r     *Reader
+	i     int
+	chain []int
+}
+
+// chain follows the link order from data block i, returning the indices of
+// the data blocks used by the file, in order. An error is returned if a link
+// is out of range or points back to an earlier block.
+func (hb *headerBlock) chain(i int) ([]int, error) {
+	var seen [numBlocks]bool
+
+	blocks := make([]int, 0, numBlocks)
+
+	for {
+		blocks, seen[i] = append(blocks, i), true
+
+		link := hb.DirectoryFrame[i].LinkOrder
+		if link == lastLink {
+			return blocks, nil
+		}
+
+		if int(link) >= numBlocks || seen[link] {
+			return nil, &FrameError{Frame: firstDirectoryFrame + i, Block: i, Err: ErrBadLink}
+		}
+
+		i = int(link)
+	}
+}
+
+// blocks returns the indices of the data blocks used by the file, in order.
+func (f *File) blocks() []int {
+	return f.chain
 }
 
 // Open returns an fs.File that provides access to the File's contents. The
 // file is prefixed with a 128 byte header (the directory frame) followed by
 // one or more 8 KiB blocks. Multiple files may be read concurrently.
 func (f *File) Open() (fs.File, error) {
-	blocks := make([]int, 0, numBlocks)
-	blocks = append(blocks, f.i)
-
-	for i := f.r.mc.HeaderBlock.DirectoryFrame[f.i].LinkOrder; i != lastLink; i = f.r.mc.HeaderBlock.DirectoryFrame[i].LinkOrder { //nolint:lll
-		blocks = append(blocks, int(i))
-	}
+	blocks := f.blocks()
 
 	readers := make([]io.Reader, 0, len(blocks)+1)
 
-	b, err := f.r.mc.HeaderBlock.DirectoryFrame[f.i].MarshalBinary()
+	b, err := f.r.hb.DirectoryFrame[f.i].MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
@@ -55,13 +80,14 @@ func (f *File) Open() (fs.File, error) {
 	readers = append(readers, bytes.NewReader(b))
 
 	for _, block := range blocks {
-		readers = append(readers, bytes.NewReader(f.r.mc.DataBlock[block][:]))
+		readers = append(readers, f.r.blockReader(block))
 	}
 
 	return &fileReader{io.NopCloser(io.MultiReader(readers...)), f}, nil
 }
 
-// FileHeader describes a file within a memory card.
+// FileHeader describes a file within a memory card. Memory cards don't record
+// modification times, so Modified is always the zero time.
 type FileHeader struct {
 	Name     string
 	Modified time.Time
@@ -105,7 +131,7 @@ type fileInfoDirEntry interface {
 
 func (e *fileListEntry) stat() (fileInfoDirEntry, error) { //nolint:ireturn
 	if e.isDup {
-		return nil, errors.New(e.name + ": duplicate entries in memory card") //nolint:goerr113
+		return nil, &FileError{Name: e.name, Err: ErrDuplicateName}
 	}
 
 	if !e.isDir {
@@ -141,29 +167,88 @@ func (e *fileListEntry) Info() (fs.FileInfo, error) { return e, nil }
 type Reader struct {
 	File []*File
 
-	mc *memoryCard
+	hb     *headerBlock
+	header []byte
+	ra     io.ReaderAt
+	format Format
+	prev   []byte // anything before the memory card, such as a header
+	next   []byte // anything after the memory card
 
 	fileListOnce sync.Once
 	fileList     []fileListEntry
 }
 
 func (r *Reader) init(nr io.Reader) error {
-	r.mc = new(memoryCard)
+	f, b, offset, err := readImage(nr)
+	if err != nil {
+		return err
+	}
+
+	r.prev, r.next = b[:offset], b[offset+cardSize:]
+
+	return r.load(f, bytes.NewReader(b[offset:offset+cardSize]))
+}
 
-	if err := r.mc.unmarshalBinary(nr); err != nil {
+func (r *Reader) initAt(ra io.ReaderAt, size int64) error {
+	f, offset, ok, err := detectFormat(ra, size, make([]byte, frameSize))
+	if err != nil {
 		return err
 	}
 
-	r.File = make([]*File, 0, r.mc.count())
+	if !ok {
+		switch {
+		case size < cardSize:
+			return ErrInvalidLength
+		case size > cardSize:
+			return ErrTrailingBytes
+		}
+	}
+
+	if offset > 0 {
+		r.prev = make([]byte, offset)
+		if _, err := ra.ReadAt(r.prev, 0); err != nil {
+			return fmt.Errorf("unable to read %s header: %w", f, err)
+		}
+	}
+
+	if n := size - offset - cardSize; n > 0 {
+		r.next = make([]byte, n)
+		if _, err := ra.ReadAt(r.next, offset+cardSize); err != nil {
+			return fmt.Errorf("unable to read %s trailer: %w", f, err)
+		}
+	}
+
+	return r.load(f, io.NewSectionReader(ra, offset, cardSize))
+}
 
-	for i := range r.mc.HeaderBlock.DirectoryFrame {
-		df := r.mc.HeaderBlock.DirectoryFrame[i]
+// load parses the header block of the memory card read by ra. The data
+// blocks are only read as needed.
+func (r *Reader) load(f Format, ra io.ReaderAt) error {
+	r.hb, r.header, r.ra, r.format = new(headerBlock), make([]byte, blockSize), ra, f
+
+	if _, err := ra.ReadAt(r.header, 0); err != nil {
+		return fmt.Errorf("unable to read header block: %w", err)
+	}
+
+	if err := r.hb.decode(r.header); err != nil {
+		return fmt.Errorf("unable to unmarshal header block: %w", err)
+	}
+
+	r.File = make([]*File, 0, r.hb.count())
+
+	for i := range r.hb.DirectoryFrame {
+		df := r.hb.DirectoryFrame[i]
 
 		if !df.isFirst() {
 			continue
 		}
 
-		f := &File{r: r, i: i}
+		chain, err := r.hb.chain(i)
+		if err != nil {
+			return err
+		}
+
+		f := &File{r: r, i: i, chain: chain}
 		f.Name = df.filename()
 		f.Size = int64(binary.Size(df) + int(df.Size))
 		f.CountryCode = df.countryCode()
@@ -335,6 +420,16 @@ func (r *Reader) Open(name string) (fs.File, error) {
 	return e.file.Open()
 }
 
+// blockReader returns an io.SectionReader for data block i.
+func (r *Reader) blockReader(i int) *io.SectionReader {
+	return io.NewSectionReader(r.ra, int64((reservedBlocks+i)*blockSize), blockSize)
+}
+
+// Format returns the format of the memory card image.
+func (r *Reader) Format() Format {
+	return r.format
+}
+
 // A ReadCloser is a Reader that must be closed when no longer needed.
 type ReadCloser struct {
 	Reader
@@ -350,7 +445,8 @@ func (rc *ReadCloser) Close() error {
 	return nil
 }
 
-// NewReader returns a new Reader reading from r.
+// NewReader returns a new Reader reading from r. The format of the memory card
+// image is detected automatically.
 func NewReader(r io.Reader) (*Reader, error) {
 	mcr := new(Reader)
 	if err := mcr.init(r); err != nil {
@@ -360,16 +456,37 @@ func NewReader(r io.Reader) (*Reader, error) {
 	return mcr, nil
 }
 
+// NewReaderAt returns a new Reader reading from r, which is assumed to have
+// the given size in bytes. The format of the memory card image is detected
+// automatically. Only the header block is read up front, the data blocks are
+// read from r as files are opened so r must remain usable for the lifetime
+// of the Reader.
+func NewReaderAt(r io.ReaderAt, size int64) (*Reader, error) {
+	mcr := new(Reader)
+	if err := mcr.initAt(r, size); err != nil {
+		return nil, err
+	}
+
+	return mcr, nil
+}
+
 // OpenReader will open the memory card image specified by name and return a
-// ReadCloser.
+// ReadCloser. As with NewReaderAt, the data blocks are read as needed.
 func OpenReader(name string) (*ReadCloser, error) {
 	f, err := os.Open(name)
 	if err != nil {
 		return nil, fmt.Errorf("unable to open: %w", err)
 	}
 
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+
+		return nil, fmt.Errorf("unable to stat: %w", err)
+	}
+
 	r := new(ReadCloser)
-	if err := r.init(f); err != nil {
+	if err := r.initAt(f, fi.Size()); err != nil {
 		f.Close()
 
 		return nil, err
@@ -1,13 +1,31 @@
 package psx_test
 
 import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"testing/fstest"
 
 	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
 )
 
+type countingReaderAt struct {
+	r io.ReaderAt
+	n int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	atomic.AddInt64(&c.n, int64(n))
+
+	return n, err
+}
+
 func TestFS(t *testing.T) {
 	t.Parallel()
 
@@ -31,3 +49,93 @@ func TestIssue83(t *testing.T) {
 	}
 	defer rc.Close()
 }
+
+func TestNewReaderAt(t *testing.T) {
+	t.Parallel()
+
+	b, err := os.ReadFile(filepath.Join("testdata", "MemoryCard2-1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gme := new(bytes.Buffer)
+	if err := psx.Convert(gme, psx.FormatDexDrive, bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []struct {
+		name   string
+		b      []byte
+		format psx.Format
+	}{
+		{"raw", b, psx.FormatRaw},
+		{"dexdrive", gme.Bytes(), psx.FormatDexDrive},
+	}
+
+	for _, table := range tables {
+		table := table
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			cr := &countingReaderAt{r: bytes.NewReader(table.b)}
+
+			r, err := psx.NewReaderAt(cr, int64(len(table.b)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, table.format, r.Format())
+			assert.Len(t, r.File, 10)
+
+			// Only the header block should have been read
+			assert.Less(t, cr.n, int64(2*blockSize))
+
+			if err := fstest.TestFS(r, "BESLES-00024TOMBRAID", "BESCES-01237TEKKEN-3"); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	_, err = psx.NewReaderAt(bytes.NewReader(b[:blockSize]), blockSize)
+	assert.ErrorIs(t, err, psx.ErrInvalidLength)
+}
+
+func TestBadLink(t *testing.T) {
+	t.Parallel()
+
+	b, err := os.ReadFile(filepath.Join("testdata", "MemoryCard2-1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []struct {
+		name string
+		link uint16
+	}{
+		{"out of range", 20},
+		{"cycle", 1},
+		{"self", 3},
+	}
+
+	for _, table := range tables {
+		table := table
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			corrupt := append([]byte{}, b...)
+
+			f := directoryFrame(corrupt, 3)
+			binary.LittleEndian.PutUint16(f[8:], table.link)
+			fixChecksum(f)
+
+			_, err := psx.NewReader(bytes.NewReader(corrupt))
+			assert.ErrorIs(t, err, psx.ErrBadLink)
+
+			frame, block, ok := errorLocation(err)
+			if assert.True(t, ok) {
+				assert.Equal(t, 4, frame)
+				assert.Equal(t, 3, block)
+			}
+		})
+	}
+}
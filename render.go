@@ -0,0 +1,169 @@
+package psx
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// The rendered memory card is a grid of three columns and five rows of slots,
+// one per block, below a line for the title of the selected file.
+const (
+	renderWidth   = 240
+	renderScale   = 2
+	fontHeight    = 13
+	renderMargin  = 8
+	renderColumns = 3
+	renderRows    = numBlocks / renderColumns
+	slotSize      = iconSize*renderScale + 2*renderMargin
+	slotBorder    = 2
+	gridX         = (renderWidth - renderColumns*slotSize) / 2
+	gridY         = 2*renderMargin + fontHeight
+	renderHeight  = gridY + renderRows*slotSize + renderMargin
+)
+
+//nolint:gochecknoglobals
+var (
+	renderBackground = image.NewUniform(color.NRGBA{0x18, 0x18, 0x48, 0xff})
+	renderSlot       = image.NewUniform(color.NRGBA{0x08, 0x08, 0x20, 0xff})
+	renderBorder     = image.NewUniform(color.NRGBA{0x80, 0x80, 0x90, 0xff})
+	renderSelected   = image.NewUniform(color.NRGBA{0xf0, 0xd0, 0x30, 0xff})
+	renderMarker     = image.NewUniform(color.NRGBA{0xc0, 0xc0, 0xc8, 0xff})
+	renderText       = image.NewUniform(color.White)
+)
+
+// slotRect returns the bounds of the slot for data block i.
+func slotRect(i int) image.Rectangle {
+	x, y := gridX+i%renderColumns*slotSize, gridY+i/renderColumns*slotSize
+
+	return image.Rect(x, y, x+slotSize, y+slotSize).Inset(slotBorder)
+}
+
+// drawSlot draws an empty slot, highlighting it if selected.
+func drawSlot(dst draw.Image, i int, selected bool) image.Rectangle {
+	border := renderBorder
+	if selected {
+		border = renderSelected
+	}
+
+	rect := slotRect(i)
+	draw.Draw(dst, rect, border, image.Point{}, draw.Src)
+	draw.Draw(dst, rect.Inset(slotBorder), renderSlot, image.Point{}, draw.Src)
+
+	return rect
+}
+
+// drawIcon draws the icon frame img scaled up in the centre of rect.
+func drawIcon(dst draw.Image, rect image.Rectangle, img image.Image) {
+	origin := rect.Min.Add(image.Pt(renderMargin-slotBorder, renderMargin-slotBorder))
+	b := img.Bounds()
+
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			pixel := image.Rect(x*renderScale, y*renderScale, (x+1)*renderScale, (y+1)*renderScale).Add(origin)
+			draw.Draw(dst, pixel, image.NewUniform(img.At(b.Min.X+x, b.Min.Y+y)), image.Point{}, draw.Over)
+		}
+	}
+}
+
+// drawMarker draws the marker for a block that continues a file.
+func drawMarker(dst draw.Image, rect image.Rectangle) {
+	c := rect.Min.Add(rect.Max).Div(2) //nolint:gomnd
+	draw.Draw(dst, image.Rect(c.X-iconSize/2, c.Y-1, c.X+iconSize/2, c.Y+1), renderMarker, image.Point{}, draw.Src)
+}
+
+// drawPlaceholder draws an empty box in place of a character the font
+// doesn't have, with dot as the origin of the character.
+func drawPlaceholder(dst draw.Image, face *basicfont.Face, dot fixed.Point26_6) {
+	x, y := dot.X.Round(), dot.Y.Round()
+	box := image.Rect(x, y-face.Ascent+1, x+face.Width-1, y)
+
+	for _, line := range []image.Rectangle{
+		image.Rect(box.Min.X, box.Min.Y, box.Max.X, box.Min.Y+1),
+		image.Rect(box.Min.X, box.Max.Y-1, box.Max.X, box.Max.Y),
+		image.Rect(box.Min.X, box.Min.Y, box.Min.X+1, box.Max.Y),
+		image.Rect(box.Max.X-1, box.Min.Y, box.Max.X, box.Max.Y),
+	} {
+		draw.Draw(dst, line, renderText, image.Point{}, draw.Src)
+	}
+}
+
+// drawTitle draws s at the top of the image. The font only covers ASCII and
+// Latin-1 so any other characters, such as the kana and kanji in Japanese
+// titles, are drawn as empty boxes.
+func drawTitle(dst draw.Image, s string) {
+	face := basicfont.Face7x13
+
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  renderText,
+		Face: face,
+		Dot:  fixed.P(renderMargin, renderMargin+face.Ascent),
+	}
+
+	for _, r := range s {
+		if _, ok := face.GlyphAdvance(r); !ok {
+			drawPlaceholder(dst, face, d.Dot)
+			d.Dot.X += fixed.I(face.Advance)
+
+			continue
+		}
+
+		d.DrawString(string(r))
+	}
+}
+
+// Render draws the memory card read by r in the style of the memory card
+// manager in the BIOS, as a grid of the 15 data blocks with the first icon
+// frame of each file in its first block and a marker in each block that
+// continues a file. If selected is a valid index into r.File then the blocks
+// of that file are highlighted and its title is drawn above the grid.
+func Render(r *Reader, selected int) (image.Image, error) {
+	img := image.NewNRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	draw.Draw(img, img.Bounds(), renderBackground, image.Point{}, draw.Src)
+
+	used := make(map[int]bool, numBlocks)
+
+	for i, f := range r.File {
+		icon, err := f.Icon()
+		if err != nil {
+			return nil, err
+		}
+
+		for j, block := range f.blocks() {
+			used[block] = true
+
+			rect := drawSlot(img, block, i == selected)
+
+			switch {
+			case j > 0:
+				drawMarker(img, rect)
+			case len(icon) > 0:
+				drawIcon(img, rect, icon[0])
+			}
+		}
+
+		if i != selected {
+			continue
+		}
+
+		title, err := f.Title()
+		if err != nil {
+			return nil, err
+		}
+
+		drawTitle(img, title)
+	}
+
+	for i := 0; i < numBlocks; i++ {
+		if !used[i] {
+			drawSlot(img, i, false)
+		}
+	}
+
+	return img, nil
+}
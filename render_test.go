@@ -0,0 +1,109 @@
+package psx_test
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
+)
+
+// slotPoint returns the point at offset x, y within the slot for block i.
+func slotPoint(i, x, y int) image.Point {
+	return image.Pt(48+i%3*48+x, 29+i/3*48+y)
+}
+
+func at(img image.Image, p image.Point) color.Color {
+	return color.NRGBAModel.Convert(img.At(p.X, p.Y))
+}
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+
+	rc, err := psx.OpenReader(filepath.Join("testdata", "MemoryCard2-1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	img, err := psx.Render(&rc.Reader, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, image.Rect(0, 0, 240, 277), img.Bounds())
+
+	// The first file uses blocks 0 to 4, the second, selected, file uses
+	// blocks 5 and 6
+	border := at(img, slotPoint(0, 2, 2))
+	selected := at(img, slotPoint(5, 2, 2))
+
+	assert.NotEqual(t, border, selected)
+	assert.Equal(t, selected, at(img, slotPoint(6, 2, 2)))
+	assert.Equal(t, border, at(img, slotPoint(7, 2, 2)))
+
+	marker := at(img, slotPoint(1, 24, 24))
+	assert.Equal(t, marker, at(img, slotPoint(6, 24, 24)))
+	assert.NotEqual(t, marker, at(img, slotPoint(0, 24, 24)))
+
+	icon, err := rc.File[2].Icon()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []image.Point{{0, 0}, {8, 8}, {15, 15}} {
+		if _, _, _, a := icon[0].At(p.X, p.Y).RGBA(); a == 0 {
+			continue
+		}
+
+		assert.Equal(t, at(icon[0], p), at(img, slotPoint(7, 8+p.X*2, 8+p.Y*2)))
+	}
+
+	// Without a selection there's no title
+	img, err = psx.Render(&rc.Reader, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	background := at(img, image.Point{})
+
+	for x := 0; x < 240; x++ {
+		for y := 0; y < 29; y++ {
+			assert.Equal(t, background, at(img, image.Pt(x, y)))
+		}
+	}
+
+	assert.Equal(t, border, at(img, slotPoint(5, 2, 2)))
+}
+
+func TestRenderPlaceholder(t *testing.T) {
+	t.Parallel()
+
+	rc, err := psx.OpenReader(filepath.Join("testdata", "m1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	// The title is entirely in Japanese which the font doesn't cover
+	img, err := psx.Render(&rc.Reader, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	background := at(img, image.Point{})
+	text := at(img, image.Pt(8, 9))
+
+	assert.NotEqual(t, background, text)
+
+	for y := 9; y < 19; y++ {
+		assert.Equal(t, text, at(img, image.Pt(8, y)))
+		assert.Equal(t, text, at(img, image.Pt(12, y)))
+	}
+
+	assert.Equal(t, background, at(img, image.Pt(10, 12)))
+	assert.Equal(t, background, at(img, image.Pt(14, 12)))
+	assert.Equal(t, text, at(img, image.Pt(15, 12)))
+}
@@ -0,0 +1,85 @@
+package psx_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	files, err := filepath.Glob(filepath.Join("testdata", "*.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		file := file
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			t.Parallel()
+
+			b, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := psx.NewReader(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			buf := new(bytes.Buffer)
+
+			w, err := psx.NewWriter(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, f := range r.File {
+				fr, err := f.Open()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				save, err := io.ReadAll(fr)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				fr.Close()
+
+				// The directory frame must be exported exactly as stored
+				// so its checksum still matches
+				df := append([]byte{}, save[:frameSize]...)
+				fixChecksum(df)
+				assert.Equal(t, save[:frameSize], df)
+
+				fw, err := w.Create()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := fw.Write(save); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := fw.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, b, buf.Bytes())
+			assert.Equal(t, b[:blockSize], r.HeaderBlock())
+		})
+	}
+}
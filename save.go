@@ -0,0 +1,235 @@
+package psx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+)
+
+// A TitleFrame describes the title and icon shown for a file by the memory
+// card manager, which are stored at the start of the first block of the file.
+type TitleFrame struct {
+	// Title is encoded as Shift-JIS with any ASCII characters replaced by
+	// their full-width forms, which allows for up to 32 characters.
+	Title string
+	// Display is the icon display flag.
+	Display IconDisplay
+	// Icon holds the 16x16 icon frames, as many as Display requires. The
+	// frames are quantised to a shared palette of 16 colours.
+	Icon []image.Image
+}
+
+// checkIcon checks the number of icon frames matches the display flag.
+func checkIcon(display byte, icon []image.Image) error {
+	if n := iconFrames(display); n == 0 || len(icon) != n {
+		return fmt.Errorf("display flag 0x%02x with %d frames: %w", display, len(icon), ErrBadIcon)
+	}
+
+	return nil
+}
+
+// size returns the number of bytes used by the title frame and icon frames.
+func (t *TitleFrame) size() int {
+	return frameSize + t.Display.Frames()*iconFrameSize
+}
+
+// encode writes the title frame and icon frames to the start of b, the first
+// block of a file using blocks blocks.
+func (t *TitleFrame) encode(b []byte, blocks int) error {
+	if err := checkIcon(byte(t.Display), t.Icon); err != nil {
+		return err
+	}
+
+	title, err := encodeTitle(t.Title)
+	if err != nil {
+		return err
+	}
+
+	palette, icon, err := encodeIcon(t.Icon)
+	if err != nil {
+		return err
+	}
+
+	copy(b, dataSignature[:])
+	b[iconDisplayOffset] = byte(t.Display)
+	b[iconDisplayOffset+1] = byte(blocks)
+
+	copy(titleBytes(b), make([]byte, titleSize))
+	copy(titleBytes(b), title)
+	copy(b[paletteOffset:], palette)
+	copy(b[iconOffset:], icon)
+
+	return nil
+}
+
+// NewSave returns a file named name, consisting of the title frame and icon
+// frames described by t followed by data, which can be written to a memory
+// card with Writer.Create. The file is padded with zeroes to a whole number of
+// blocks. The name should be the country code, product code and identifier,
+// such as "BASLUS-00594SAVE0".
+func NewSave(name string, t *TitleFrame, data []byte) ([]byte, error) {
+	if name == "" || len(name) > filenameSize {
+		return nil, &FileError{Name: name, Err: ErrInvalidLength}
+	}
+
+	size := t.size() + len(data)
+
+	blocks := (size + blockSize - 1) / blockSize
+	if blocks > numBlocks {
+		return nil, &LengthError{Name: name, Expected: numBlocks * blockSize, Actual: int64(size)}
+	}
+
+	df := newDirectoryFrame()
+	df.AvailableBlocks = blockFirstLink
+	df.Size = uint32(blocks * blockSize)
+
+	header, err := df.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	copy(header[filenameOffset:], name)
+	copy(header[frameSize-1:], checksum(header[:frameSize-1]))
+
+	b := make([]byte, frameSize+blocks*blockSize)
+	copy(b, header)
+
+	if err := t.encode(b[frameSize:], blocks); err != nil {
+		return nil, &FileError{Name: name, Err: err}
+	}
+
+	copy(b[frameSize+t.size():], data)
+
+	return b, nil
+}
+
+// firstBlock returns the first block of the file in b after checking it
+// starts with a title frame.
+func firstBlock(b []byte) ([]byte, error) {
+	if len(b) < frameSize+blockSize {
+		return nil, &LengthError{Expected: frameSize + blockSize, Actual: int64(len(b))}
+	}
+
+	block := b[frameSize : frameSize+blockSize]
+	if !bytes.Equal(block[:len(dataSignature)], dataSignature[:]) {
+		return nil, ErrBadDataSignature
+	}
+
+	return block, nil
+}
+
+// SetTitle replaces the title of the file in b, which is as read with
+// File.Open or returned by NewSave. The title is encoded as described by
+// TitleFrame. An edited file can be written back over the original with
+// Writer.Replace.
+func SetTitle(b []byte, title string) error {
+	block, err := firstBlock(b)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := encodeTitle(title)
+	if err != nil {
+		return err
+	}
+
+	copy(titleBytes(block), make([]byte, titleSize))
+	copy(titleBytes(block), encoded)
+
+	return nil
+}
+
+// SetIcon replaces the icon frames of the file in b, which is as read with
+// File.Open or returned by NewSave. The number of frames must match the icon
+// display flag of the file as the data following the icon frames can't be
+// moved, however a file with an animated icon can be given a static icon by
+// repeating the same frame.
+func SetIcon(b []byte, icon []image.Image) error {
+	block, err := firstBlock(b)
+	if err != nil {
+		return err
+	}
+
+	if err := checkIcon(block[iconDisplayOffset], icon); err != nil {
+		return err
+	}
+
+	palette, frames, err := encodeIcon(icon)
+	if err != nil {
+		return err
+	}
+
+	copy(block[paletteOffset:], palette)
+	copy(block[iconOffset:], frames)
+
+	return nil
+}
+
+// SaveName returns the name of the file in b, which is as read with File.Open
+// or returned by NewSave.
+func SaveName(b []byte) string {
+	return cstring(b[filenameOffset : filenameOffset+filenameSize])
+}
+
+// RenameSave renames the file in b, which is as read with File.Open or
+// returned by NewSave. Only the identifier can be changed, the country and
+// product codes at the start of the name must stay the same.
+func RenameSave(b []byte, name string) error {
+	old := SaveName(b)
+	prefix := identifierOffset - filenameOffset
+
+	if len(name) > filenameSize || len(name) < prefix || len(old) < prefix || name[:prefix] != old[:prefix] {
+		return &FileError{Name: name, Err: ErrBadName}
+	}
+
+	for _, r := range name {
+		if r < ' ' || r > '~' || r == '/' {
+			return &FileError{Name: name, Err: ErrBadName}
+		}
+	}
+
+	field := b[filenameOffset : filenameOffset+filenameSize]
+	copy(field, make([]byte, filenameSize))
+	copy(field, name)
+	copy(b[frameSize-1:], checksum(b[:frameSize-1]))
+
+	return nil
+}
+
+// ValidateSave checks the file in b looks like a file that can be written to
+// a memory card with Writer.Create.
+func ValidateSave(b []byte) error {
+	switch {
+	case len(b) < frameSize+blockSize || len(b) > MaxSaveSize:
+		return &LengthError{Expected: MaxSaveSize, Actual: int64(len(b))}
+	case b[0] != blockFirstLink:
+		return ErrBadAllocation
+	case !bytes.Equal(checksum(b[:frameSize-1]), b[frameSize-1:frameSize]):
+		return ErrBadDirectoryChecksum
+	}
+
+	if _, err := firstBlock(b); err != nil {
+		return err
+	}
+
+	// The size in the directory frame has to agree with the blocks that
+	// follow it, this isn't a LengthError as the file isn't too large, it's
+	// inconsistent
+	if size := binary.LittleEndian.Uint32(b[4:]); int64(size) != int64(len(b)-frameSize) {
+		return fmt.Errorf("%w: directory frame has %d bytes, got %d", ErrInvalidLength, size, len(b)-frameSize)
+	}
+
+	return nil
+}
+
+// ExportSave returns a copy of the file in b, as read with File.Open, as a
+// file on its own in the MCS format, with the link order in its directory
+// frame cleared as it only makes sense on the original memory card.
+func ExportSave(b []byte) []byte {
+	b = append([]byte{}, b...)
+	binary.LittleEndian.PutUint16(b[8:], lastLink)
+	copy(b[frameSize-1:], checksum(b[:frameSize-1]))
+
+	return b
+}
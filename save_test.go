@@ -0,0 +1,553 @@
+package psx_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
+)
+
+func solidIcon(c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for i := 0; i < 16*16; i++ {
+		img.Set(i%16, i/16, c)
+	}
+
+	return img
+}
+
+func gradientIcon() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for i := 0; i < 16*16; i++ {
+		img.Set(i%16, i/16, color.NRGBA{uint8(i % 16 * 16), uint8(i / 16 * 16), 0x80, 0xff})
+	}
+
+	return img
+}
+
+//nolint:funlen
+func TestNewSave(t *testing.T) {
+	t.Parallel()
+
+	red := solidIcon(color.NRGBA{0xff, 0, 0, 0xff})
+	red.Set(0, 0, color.Transparent)
+
+	tf := &psx.TitleFrame{
+		Title:   "Test Save ~1",
+		Display: psx.IconAnimated2,
+		Icon:    []image.Image{red, gradientIcon()},
+	}
+
+	save, err := psx.NewSave("BESLES-00000SAVE", tf, make([]byte, blockSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, save, frameSize+2*blockSize)
+
+	buf := new(bytes.Buffer)
+
+	w, err := psx.NewWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fw, err := w.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write(save); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := psx.Check(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, problems)
+
+	r, err := psx.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.Len(t, r.File, 1) {
+		return
+	}
+
+	title, err := r.File[0].Title()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "Test Save ~1", title)
+
+	b, err := r.Block(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []byte{'S', 'C', 0x12, 2}, b[:4])
+
+	// Transparency is always the first colour, and red is exact
+	palette := make([]uint16, 16)
+	for i := range palette {
+		palette[i] = binary.LittleEndian.Uint16(b[0x60+i*2:])
+	}
+
+	assert.Equal(t, uint16(0), palette[0])
+	assert.Equal(t, byte(0), b[0x80]&0x0f)
+	assert.Equal(t, uint16(0x1f), palette[b[0x80]>>4])
+
+	// The gradient has been reduced to the remaining colours
+	seen := make(map[byte]struct{})
+	for _, x := range b[0x100:0x180] {
+		seen[x&0x0f], seen[x>>4] = struct{}{}, struct{}{}
+	}
+
+	assert.NotContains(t, seen, byte(0))
+	assert.Greater(t, len(seen), 8)
+
+	fr, err := r.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fr.Close()
+
+	got, err := io.ReadAll(fr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The Writer fills in the link order
+	assert.Equal(t, save[frameSize:], got[frameSize:])
+}
+
+func titleFrame(title string, display psx.IconDisplay, icon ...image.Image) *psx.TitleFrame {
+	return &psx.TitleFrame{Title: title, Display: display, Icon: icon}
+}
+
+func TestNewSaveErrors(t *testing.T) {
+	t.Parallel()
+
+	icon := solidIcon(color.Black)
+	name := "BESLES-00000SAVE"
+
+	tables := []struct {
+		name  string
+		file  string
+		frame *psx.TitleFrame
+		data  []byte
+		err   error
+	}{
+		{"frames", name, titleFrame("", psx.IconAnimated3, icon), nil, psx.ErrBadIcon},
+		{"display", name, titleFrame("", 0, icon), nil, psx.ErrBadIcon},
+		{"icon size", name, titleFrame("", psx.IconStatic, image.NewNRGBA(image.Rect(0, 0, 8, 8))), nil, psx.ErrBadIcon},
+		{"title", name, titleFrame("This title is far too long to fit", psx.IconStatic, icon), nil, psx.ErrInvalidLength},
+		{"name", name + "GAME01", titleFrame("", psx.IconStatic, icon), nil, psx.ErrInvalidLength},
+		{"data", name, titleFrame("", psx.IconStatic, icon), make([]byte, 15*blockSize), psx.ErrInvalidLength},
+	}
+
+	for _, table := range tables {
+		table := table
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := psx.NewSave(table.file, table.frame, table.data)
+			assert.ErrorIs(t, err, table.err)
+		})
+	}
+
+	_, err := psx.NewSave(name, titleFrame("Café", psx.IconStatic, icon), nil)
+	assert.Error(t, err)
+}
+
+func animatedIcon(t *testing.T, colours ...color.Color) []byte {
+	t.Helper()
+
+	g := new(gif.GIF)
+
+	for _, c := range colours {
+		img := image.NewPaletted(image.Rect(0, 0, 16, 16), color.Palette{color.Black, c})
+		for i := range img.Pix {
+			img.Pix[i] = uint8(i % 2)
+		}
+
+		g.Image = append(g.Image, img)
+		g.Delay = append(g.Delay, 0)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := gif.EncodeAll(buf, g); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+//nolint:cyclop,funlen
+func TestSetTitleAndIcon(t *testing.T) {
+	t.Parallel()
+
+	rc, err := psx.OpenReader(filepath.Join("testdata", "m1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	f := rc.File[0]
+
+	old, err := f.Icon()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fr, err := f.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fr.Close()
+
+	b, err := io.ReadAll(fr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	colours := []color.Color{
+		color.NRGBA{0xff, 0, 0, 0xff},
+		color.NRGBA{0, 0xff, 0, 0xff},
+		color.NRGBA{0, 0, 0xff, 0xff},
+	}
+
+	icon, err := psx.DecodeIcon(bytes.NewReader(animatedIcon(t, colours[:len(old)]...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited := append([]byte{}, b...)
+
+	assert.ErrorIs(t, psx.SetIcon(edited, append(icon, icon[0])), psx.ErrBadIcon)
+	assert.ErrorIs(t, psx.SetTitle(edited[:frameSize], "Short"), psx.ErrInvalidLength)
+
+	if err := psx.SetTitle(edited, "Translated title"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := psx.SetIcon(edited, icon); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the title, palette and icon frames have changed
+	assert.Equal(t, b[:frameSize+4], edited[:frameSize+4])
+	assert.Equal(t, b[frameSize+0x80+len(old)*0x80:], edited[frameSize+0x80+len(old)*0x80:])
+
+	buf := new(bytes.Buffer)
+
+	w, err := psx.NewWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fw, err := w.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write(edited); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := psx.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	title, err := r.File[0].Title()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "Translated title", title)
+
+	frames, err := r.File[0].Icon()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, frames, len(old)) {
+		for i, frame := range frames {
+			assert.Equal(t, color.NRGBAModel.Convert(color.Black), color.NRGBAModel.Convert(frame.At(0, 0)))
+			assert.Equal(t, colours[i], color.NRGBAModel.Convert(frame.At(1, 0)))
+		}
+	}
+}
+
+func TestReplaceTitle(t *testing.T) {
+	t.Parallel()
+
+	b, err := os.ReadFile(filepath.Join("testdata", "m1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(t.TempDir(), "m1.mcd")
+
+	if err := os.WriteFile(name, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	const save = "BASLUS-01040VAG1"
+
+	r, err := psx.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited, err := fs.ReadFile(r, save)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := psx.SetTitle(edited, "Edited title"); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := psx.OpenForUpdate(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Creating the file again is a duplicate, it has to be replaced
+	assert.ErrorIs(t, writeSave(w, edited), psx.ErrDuplicateName)
+
+	fw, err := w.Replace(save)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write(edited); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := psx.OpenReader(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	assert.Len(t, rc.File, len(r.File))
+
+	var f *psx.File
+
+	for _, file := range rc.File {
+		if file.Name == save {
+			f = file
+		}
+	}
+
+	if !assert.NotNil(t, f) {
+		return
+	}
+
+	title, err := f.Title()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "Edited title", title)
+
+	// The file was replaced in place so only its title has changed
+	updated, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The file starts at data block 10, after the header block
+	block := 11 * blockSize
+
+	assert.Equal(t, b[:block], updated[:block])
+	assert.Equal(t, b[block+0x44:], updated[block+0x44:])
+}
+
+func exportSave(t *testing.T, name string) []byte {
+	t.Helper()
+
+	rc, err := psx.OpenReader(filepath.Join("testdata", "m1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	b, err := fs.ReadFile(rc, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return psx.ExportSave(b)
+}
+
+func TestValidateSave(t *testing.T) {
+	t.Parallel()
+
+	save := exportSave(t, "BASLUS-00603-DASH00")
+
+	tables := map[string]struct {
+		edit func([]byte) []byte
+		err  error
+	}{
+		"valid": {
+			edit: func(b []byte) []byte { return b },
+		},
+		"too short": {
+			edit: func(b []byte) []byte { return b[:128] },
+			err:  psx.ErrInvalidLength,
+		},
+		"too long": {
+			edit: func(b []byte) []byte { return append(b, make([]byte, psx.MaxSaveSize)...) },
+			err:  psx.ErrInvalidLength,
+		},
+		"empty": {
+			edit: func(b []byte) []byte { return nil },
+			err:  psx.ErrInvalidLength,
+		},
+		"bad allocation": {
+			edit: func(b []byte) []byte {
+				b[0] = 0xa1
+				b[127] ^= 0x51 ^ 0xa1
+
+				return b
+			},
+			err: psx.ErrBadAllocation,
+		},
+		"bad checksum": {
+			edit: func(b []byte) []byte {
+				b[0x20] ^= 0xff
+
+				return b
+			},
+			err: psx.ErrBadDirectoryChecksum,
+		},
+		"extra block": {
+			edit: func(b []byte) []byte { return append(b, make([]byte, 8192)...) },
+			err:  psx.ErrInvalidLength,
+		},
+		"size mismatch": {
+			edit: func(b []byte) []byte {
+				b[5] = 0x40
+				b[127] ^= 0x20 ^ 0x40
+
+				return b
+			},
+			err: psx.ErrInvalidLength,
+		},
+		"bad signature": {
+			edit: func(b []byte) []byte {
+				b[128] = 'X'
+
+				return b
+			},
+			err: psx.ErrBadDataSignature,
+		},
+	}
+
+	for name, table := range tables {
+		name, table := name, table
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := psx.ValidateSave(table.edit(append([]byte{}, save...)))
+			if table.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, table.err)
+			}
+		})
+	}
+}
+
+func TestRenameSave(t *testing.T) {
+	t.Parallel()
+
+	save := exportSave(t, "BASLUS-00603-DASH00")
+
+	tables := map[string]struct {
+		name string
+		err  error
+	}{
+		"identifier": {
+			name: "BASLUS-00603-OTHER",
+		},
+		"no identifier": {
+			name: "BASLUS-00603",
+		},
+		"prefix change": {
+			name: "BESLES-00603-DASH00",
+			err:  psx.ErrBadName,
+		},
+		"too short": {
+			name: "BASLUS",
+			err:  psx.ErrBadName,
+		},
+		"too long": {
+			name: "BASLUS-00603-" + strings.Repeat("X", 8),
+			err:  psx.ErrBadName,
+		},
+		"slash": {
+			name: "BASLUS-00603/DASH",
+			err:  psx.ErrBadName,
+		},
+		"control character": {
+			name: "BASLUS-00603\tDASH",
+			err:  psx.ErrBadName,
+		},
+	}
+
+	for name, table := range tables {
+		name, table := name, table
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := append([]byte{}, save...)
+
+			err := psx.RenameSave(b, table.name)
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+				assert.Equal(t, save, b)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, table.name, psx.SaveName(b))
+			assert.NoError(t, psx.ValidateSave(b))
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package psx
+
+import (
+	"fmt"
+	"io"
+)
+
+// A ScannedFile describes a file found by a Scanner. The byte slices refer to
+// the buffers of the Scanner so are only valid until the next scan.
+type ScannedFile struct {
+	// Block is the index of the first data block of the file.
+	Block int
+	// Size is the size of the file, including the 128 byte header, as
+	// reported by File.
+	Size        int64
+	Name        []byte
+	CountryCode []byte
+	ProductCode []byte
+	Identifier  []byte
+}
+
+// A Scanner reads the directories of many memory card images in turn. Unlike
+// a Reader, only the header block is read and the buffers are reused between
+// memory card images so scanning allocates almost nothing.
+type Scanner struct {
+	// Files lists the files found by the last successful call to Scan.
+	Files []ScannedFile
+
+	format Format
+	hb     headerBlock
+	header [blockSize]byte
+	buf    [frameSize]byte
+}
+
+// NewScanner returns a new Scanner.
+func NewScanner() *Scanner {
+	return &Scanner{
+		Files: make([]ScannedFile, 0, numBlocks),
+	}
+}
+
+// Scan reads the directory of the memory card image of size bytes read by r,
+// in any supported format.
+func (s *Scanner) Scan(r io.ReaderAt, size int64) error {
+	s.Files = s.Files[:0]
+
+	f, offset, ok, err := detectFormat(r, size, s.buf[:])
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		switch {
+		case size < cardSize:
+			return ErrInvalidLength
+		case size > cardSize:
+			return ErrTrailingBytes
+		}
+	}
+
+	if _, err := r.ReadAt(s.header[:], offset); err != nil {
+		return fmt.Errorf("unable to read header block: %w", err)
+	}
+
+	if err := s.hb.decode(s.header[:]); err != nil {
+		return fmt.Errorf("unable to unmarshal header block: %w", err)
+	}
+
+	s.format = f
+
+	for i := range s.hb.DirectoryFrame {
+		df := &s.hb.DirectoryFrame[i]
+		if !df.isFirst() {
+			continue
+		}
+
+		b := s.header[(firstDirectoryFrame+i)*frameSize:]
+
+		s.Files = append(s.Files, ScannedFile{
+			Block:       i,
+			Size:        int64(frameSize) + int64(df.Size),
+			Name:        cbytes(b[filenameOffset : filenameOffset+filenameSize]),
+			CountryCode: cbytes(b[filenameOffset : filenameOffset+len(df.CountryCode)]),
+			ProductCode: cbytes(b[filenameOffset+len(df.CountryCode) : identifierOffset]),
+			Identifier:  cbytes(b[identifierOffset : filenameOffset+filenameSize]),
+		})
+	}
+
+	return nil
+}
+
+// Format returns the format of the memory card image read by the last
+// successful call to Scan.
+func (s *Scanner) Format() Format {
+	return s.format
+}
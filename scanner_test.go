@@ -0,0 +1,124 @@
+package psx_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanner(t *testing.T) {
+	t.Parallel()
+
+	s := psx.NewScanner()
+
+	for _, file := range []string{"m1.mcd", "MemoryCard2-1.mcd", "blank.mcd"} {
+		b, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := psx.NewReader(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Scan(bytes.NewReader(b), int64(len(b))); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, r.Format(), s.Format())
+
+		if !assert.Len(t, s.Files, len(r.File)) {
+			continue
+		}
+
+		for i, f := range r.File {
+			assert.Equal(t, f.Name, string(s.Files[i].Name))
+			assert.Equal(t, f.Size, s.Files[i].Size)
+			assert.Equal(t, f.CountryCode, string(s.Files[i].CountryCode))
+			assert.Equal(t, f.ProductCode, string(s.Files[i].ProductCode))
+			assert.Equal(t, f.Identifier, string(s.Files[i].Identifier))
+		}
+	}
+
+	assert.ErrorIs(t, s.Scan(bytes.NewReader(nil), 0), psx.ErrInvalidLength)
+	assert.Empty(t, s.Files)
+}
+
+//nolint:paralleltest
+func TestScannerAllocs(t *testing.T) {
+	// testing.AllocsPerRun can't be used by parallel tests
+	b, err := os.ReadFile(filepath.Join("testdata", "MemoryCard2-1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, s := bytes.NewReader(b), psx.NewScanner()
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := s.Scan(r, int64(len(b))); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	assert.Zero(t, allocs)
+}
+
+func benchmarkCard(b *testing.B) []byte {
+	b.Helper()
+
+	card, err := os.ReadFile(filepath.Join("testdata", "MemoryCard2-1.mcd"))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return card
+}
+
+func BenchmarkNewReader(b *testing.B) {
+	card := benchmarkCard(b)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(card)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := psx.NewReader(bytes.NewReader(card)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewReaderAt(b *testing.B) {
+	card := benchmarkCard(b)
+	r := bytes.NewReader(card)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(card)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := psx.NewReaderAt(r, int64(len(card))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkScanner(b *testing.B) {
+	card := benchmarkCard(b)
+	r, s := bytes.NewReader(card), psx.NewScanner()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(card)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := s.Scan(r, int64(len(card))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,528 @@
+// Package server provides an http.Handler for browsing and editing a
+// directory of Sony PlayStation 1 memory card images.
+//
+// The handler serves the following, where card is the slash-separated path of
+// a memory card image within the directory and save is the name of a file on
+// that memory card, each escaped as a single path segment:
+//
+//	GET    /cards                         list each memory card and its files
+//	GET    /cards/{card}                  list the files on a memory card
+//	GET    /cards/{card}/image            download a memory card
+//	GET    /cards/{card}/saves/{save}     download a file
+//	POST   /cards/{card}/saves            upload a file
+//	DELETE /cards/{card}/saves/{save}     delete a file
+//	POST   /cards/{card}/saves/{save}/move?to={card}
+//	                                      move a file to another memory card
+//
+// Memory cards and files can be downloaded in another format with a format
+// query parameter, such as "?format=dexdrive". Files are otherwise downloaded
+// and uploaded in the MCS format, which is the directory frame of the file
+// followed by its blocks, and with a format they are downloaded on a memory
+// card of their own.
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bodgit/psx"
+	"github.com/bodgit/psx/internal/cardfile"
+)
+
+var (
+	errNotFound      = errors.New("not found")
+	errMethod        = errors.New("method not allowed")
+	errSameCard      = errors.New("file is already on that memory card")
+	errMissingTarget = errors.New("missing memory card to move to")
+	errInvalidCard   = errors.New("invalid memory card name")
+)
+
+// A Save describes a file on a memory card.
+type Save struct {
+	Name   string `json:"name"`
+	Title  string `json:"title"`
+	Serial string `json:"serial"`
+	Size   int64  `json:"size"`
+	Blocks int    `json:"blocks"`
+}
+
+// A Card describes a memory card image.
+type Card struct {
+	Name   string `json:"name"`
+	Format string `json:"format"`
+	Free   int    `json:"free"`
+	Saves  []Save `json:"saves"`
+}
+
+// A Handler serves a directory of memory card images. Changes to a memory
+// card are written atomically so a failed request leaves it untouched.
+type Handler struct {
+	mu  sync.RWMutex
+	dir string
+}
+
+// New returns a Handler serving the memory card images within dir.
+func New(dir string) *Handler {
+	return &Handler{dir: dir}
+}
+
+// path returns the path of the memory card image named by card.
+func (h *Handler) path(card string) (string, error) {
+	if !fs.ValidPath(card) || card == "." {
+		return "", errInvalidCard
+	}
+
+	return filepath.Join(h.dir, filepath.FromSlash(card)), nil
+}
+
+func (h *Handler) open(card string) (*psx.ReadCloser, error) {
+	name, err := h.path(card)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := psx.OpenReader(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", card, err)
+	}
+
+	return rc, nil
+}
+
+func describe(card string, r *psx.Reader) (*Card, error) {
+	c := &Card{
+		Name:   card,
+		Format: r.Format().String(),
+		Free:   psx.NumBlocks,
+		Saves:  make([]Save, 0, len(r.File)),
+	}
+
+	for _, f := range r.File {
+		title, err := f.Title()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+
+		s := Save{
+			Name:   f.Name,
+			Title:  title,
+			Serial: f.Serial(),
+			Size:   f.Size,
+			Blocks: int(f.Size / psx.BlockSize),
+		}
+
+		c.Free -= s.Blocks
+		c.Saves = append(c.Saves, s)
+	}
+
+	return c, nil
+}
+
+func (h *Handler) card(card string) (*Card, error) {
+	rc, err := h.open(card)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return describe(card, &rc.Reader)
+}
+
+// cards returns every memory card image found within the directory.
+func (h *Handler) cards() ([]*Card, error) {
+	cards := []*Card{}
+
+	if err := fs.WalkDir(os.DirFS(h.dir), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		c, err := h.card(name)
+		if err != nil {
+			// Skip anything that isn't a memory card
+			return nil //nolint:nilerr
+		}
+
+		cards = append(cards, c)
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("unable to walk: %w", err)
+	}
+
+	return cards, nil
+}
+
+// add adds the file in b to the memory card image named by card.
+func (h *Handler) add(card string, b []byte) error {
+	name, err := h.path(card)
+	if err != nil {
+		return err
+	}
+
+	if err := cardfile.Add(name, b); err != nil {
+		return fmt.Errorf("%s: %w", card, err)
+	}
+
+	return nil
+}
+
+// remove removes the named file from the memory card image named by card.
+func (h *Handler) remove(card, save string) error {
+	name, err := h.path(card)
+	if err != nil {
+		return err
+	}
+
+	if err := cardfile.Remove(name, save); err != nil {
+		return fmt.Errorf("%s: %w", card, err)
+	}
+
+	return nil
+}
+
+// corrupt reports whether err is from a memory card image that is damaged,
+// rather than from anything sent in the request.
+func corrupt(err error) bool {
+	var (
+		fe *psx.FrameError
+		ce *psx.ChecksumError
+	)
+
+	return errors.As(err, &fe) || errors.As(err, &ce) ||
+		errors.Is(err, psx.ErrBadHeaderSignature) || errors.Is(err, psx.ErrBadHeaderChecksum) ||
+		errors.Is(err, psx.ErrTrailingBytes)
+}
+
+// failed reports whether err is from reading or writing the directory.
+func failed(err error) bool {
+	var (
+		pe *fs.PathError
+		le *os.LinkError
+		se *os.SyscallError
+	)
+
+	return errors.As(err, &pe) || errors.As(err, &le) || errors.As(err, &se)
+}
+
+// status returns the HTTP status code for err.
+//
+//nolint:cyclop
+func status(err error) int {
+	var le *psx.LengthError
+
+	switch {
+	case errors.Is(err, errNotFound), errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, errMethod):
+		return http.StatusMethodNotAllowed
+	case errors.Is(err, psx.ErrNoFreeSpace):
+		return http.StatusInsufficientStorage
+	case errors.Is(err, psx.ErrDuplicateName):
+		return http.StatusConflict
+	case errors.Is(err, psx.ErrUnsupportedFormat):
+		return http.StatusUnprocessableEntity
+	case errors.As(err, &le) && le.Actual > le.Expected:
+		return http.StatusRequestEntityTooLarge
+	case corrupt(err), failed(err):
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status(err))
+
+	_ = json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeFile(w http.ResponseWriter, name string, b []byte) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+
+	_, _ = w.Write(b)
+}
+
+// format returns the format given by the query parameter, if any.
+func format(r *http.Request) (psx.Format, bool, error) {
+	s := r.URL.Query().Get("format")
+	if s == "" {
+		return 0, false, nil
+	}
+
+	f, err := psx.ParseFormat(s)
+	if err != nil {
+		return 0, false, err //nolint:wrapcheck
+	}
+
+	return f, true, nil
+}
+
+// segments splits the escaped path into unescaped segments.
+func segments(p string) ([]string, error) {
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+
+	for i, part := range parts {
+		s, err := url.PathUnescape(part)
+		if err != nil {
+			return nil, fmt.Errorf("unable to unescape path: %w", err)
+		}
+
+		parts[i] = s
+	}
+
+	return parts, nil
+}
+
+func (h *Handler) getImage(w http.ResponseWriter, r *http.Request, card string) error {
+	f, ok, err := format(r)
+	if err != nil {
+		return err
+	}
+
+	name, err := h.path(card)
+	if err != nil {
+		return err
+	}
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", card, err)
+	}
+
+	if !ok {
+		writeFile(w, path.Base(card), b)
+
+		return nil
+	}
+
+	buf := new(bytes.Buffer)
+	if err := psx.Convert(buf, f, bytes.NewReader(b)); err != nil {
+		return fmt.Errorf("%s: %w", card, err)
+	}
+
+	writeFile(w, strings.TrimSuffix(path.Base(card), path.Ext(card))+f.Extension(), buf.Bytes())
+
+	return nil
+}
+
+func (h *Handler) getSave(w http.ResponseWriter, r *http.Request, card, save string) error {
+	f, ok, err := format(r)
+	if err != nil {
+		return err
+	}
+
+	rc, err := h.open(card)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	b, err := cardfile.Read(&rc.Reader, save)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		writeFile(w, save+".mcs", b)
+
+		return nil
+	}
+
+	buf := new(bytes.Buffer)
+
+	mw, err := psx.NewWriterWithFormat(buf, f)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if err := cardfile.Write(mw, b); err != nil {
+		return err
+	}
+
+	if err := mw.Close(); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	writeFile(w, save+f.Extension(), buf.Bytes())
+
+	return nil
+}
+
+func (h *Handler) postSave(w http.ResponseWriter, r *http.Request, card string) error {
+	b, err := io.ReadAll(io.LimitReader(r.Body, psx.MaxSaveSize+1))
+	if err != nil {
+		return fmt.Errorf("unable to read request: %w", err)
+	}
+
+	if err := h.add(card, b); err != nil {
+		return err
+	}
+
+	c, err := h.card(card)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusCreated, c)
+
+	return nil
+}
+
+func (h *Handler) deleteSave(w http.ResponseWriter, card, save string) error {
+	c, err := h.card(card)
+	if err != nil {
+		return err
+	}
+
+	if !c.has(save) {
+		return fmt.Errorf("%s: %w", save, errNotFound)
+	}
+
+	if err := h.remove(card, save); err != nil {
+		return err
+	}
+
+	if c, err = h.card(card); err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, c)
+
+	return nil
+}
+
+func (c *Card) has(save string) bool {
+	for _, s := range c.Saves {
+		if s.Name == save {
+			return true
+		}
+	}
+
+	return false
+}
+
+// moveSave adds the file to the destination memory card first so it's never
+// lost, even if removing it from the source memory card then fails.
+func (h *Handler) moveSave(w http.ResponseWriter, r *http.Request, card, save string) error {
+	to := r.URL.Query().Get("to")
+
+	switch {
+	case to == "":
+		return errMissingTarget
+	case path.Clean(to) == path.Clean(card):
+		return errSameCard
+	}
+
+	rc, err := h.open(card)
+	if err != nil {
+		return err
+	}
+
+	b, err := cardfile.Read(&rc.Reader, save)
+	rc.Close()
+
+	if err != nil {
+		return err
+	}
+
+	if err := h.add(to, b); err != nil {
+		return err
+	}
+
+	if err := h.remove(card, save); err != nil {
+		return err
+	}
+
+	c, err := h.card(to)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, c)
+
+	return nil
+}
+
+//nolint:cyclop
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) error {
+	parts, err := segments(r.URL.EscapedPath())
+	if err != nil {
+		return err
+	}
+
+	if parts[0] != "cards" {
+		return errNotFound
+	}
+
+	get := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if get {
+		h.mu.RLock()
+		defer h.mu.RUnlock()
+	} else {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+	}
+
+	switch {
+	case len(parts) == 1 && get:
+		cards, err := h.cards()
+		if err != nil {
+			return err
+		}
+
+		writeJSON(w, http.StatusOK, cards)
+	case len(parts) == 2 && get:
+		c, err := h.card(parts[1])
+		if err != nil {
+			return err
+		}
+
+		writeJSON(w, http.StatusOK, c)
+	case len(parts) == 3 && parts[2] == "image" && get:
+		return h.getImage(w, r, parts[1])
+	case len(parts) == 3 && parts[2] == "saves" && r.Method == http.MethodPost:
+		return h.postSave(w, r, parts[1])
+	case len(parts) == 4 && parts[2] == "saves" && get:
+		return h.getSave(w, r, parts[1], parts[3])
+	case len(parts) == 4 && parts[2] == "saves" && r.Method == http.MethodDelete:
+		return h.deleteSave(w, parts[1], parts[3])
+	case len(parts) == 5 && parts[2] == "saves" && parts[4] == "move" && r.Method == http.MethodPost:
+		return h.moveSave(w, r, parts[1], parts[3])
+	case len(parts) <= 5:
+		return errMethod
+	default:
+		return errNotFound
+	}
+
+	return nil
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.serve(w, r); err != nil {
+		writeError(w, err)
+	}
+}
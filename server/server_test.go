@@ -0,0 +1,249 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/bodgit/psx/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func copyCard(t *testing.T, src, dst string) {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join("..", "testdata", src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(dst, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func do(t *testing.T, h http.Handler, method, target string, body []byte, v interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, r))
+
+	if v != nil && w.Code < http.StatusBadRequest {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return w
+}
+
+//nolint:funlen
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	copyCard(t, "m1.mcd", filepath.Join(dir, "m1.mcd"))
+	copyCard(t, "MemoryCard2-1.mcd", filepath.Join(dir, "sub", "full.mcd"))
+	copyCard(t, "blank.mcd", filepath.Join(dir, "blank.mcd"))
+
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	h := server.New(dir)
+	full := "/cards/" + url.PathEscape("sub/full.mcd")
+
+	var cards []server.Card
+
+	w := do(t, h, http.MethodGet, "/cards", nil, &cards)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, cards, 3)
+
+	var card server.Card
+
+	do(t, h, http.MethodGet, full, nil, &card)
+	assert.Equal(t, "sub/full.mcd", card.Name)
+	assert.Equal(t, 0, card.Free)
+
+	do(t, h, http.MethodGet, "/cards/m1.mcd", nil, &card)
+
+	if !assert.Len(t, card.Saves, 10) {
+		return
+	}
+
+	first, second := card.Saves[0], card.Saves[1]
+
+	w = do(t, h, http.MethodGet, "/cards/m1.mcd/saves/"+url.PathEscape(first.Name), nil, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, w.Body.Bytes(), int(first.Size))
+
+	save := w.Body.Bytes()
+
+	w = do(t, h, http.MethodGet, "/cards/m1.mcd/image?format=dexdrive", nil, nil)
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		f, ok, err := psx.DetectFormat(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, psx.FormatDexDrive, f)
+	}
+
+	w = do(t, h, http.MethodPost, "/cards/blank.mcd/saves", save, &card)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Len(t, card.Saves, 1)
+
+	w = do(t, h, http.MethodPost, "/cards/blank.mcd/saves", save, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Moving onto a full memory card leaves the file where it was
+	w = do(t, h, http.MethodPost, "/cards/m1.mcd/saves/"+url.PathEscape(second.Name)+"/move?to=sub/full.mcd", nil, nil)
+	assert.Equal(t, http.StatusInsufficientStorage, w.Code)
+
+	w = do(t, h, http.MethodPost, "/cards/m1.mcd/saves/"+url.PathEscape(second.Name)+"/move?to=blank.mcd", nil, &card)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, card.Saves, 2)
+
+	do(t, h, http.MethodGet, "/cards/m1.mcd", nil, &card)
+	assert.Len(t, card.Saves, 9)
+
+	w = do(t, h, http.MethodDelete, "/cards/blank.mcd/saves/"+url.PathEscape(first.Name), nil, &card)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, card.Saves, 1)
+
+	tables := []struct {
+		method, target string
+		code           int
+	}{
+		{http.MethodGet, "/cards/missing.mcd", http.StatusNotFound},
+		{http.MethodGet, "/cards/m1.mcd/saves/missing", http.StatusNotFound},
+		{http.MethodDelete, "/cards/m1.mcd/saves/missing", http.StatusNotFound},
+		{http.MethodGet, "/other", http.StatusNotFound},
+		{http.MethodPut, "/cards", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/cards/" + url.PathEscape("../m1.mcd"), http.StatusBadRequest},
+		{http.MethodGet, "/cards/m1.mcd/image?format=floppy", http.StatusBadRequest},
+	}
+
+	for _, table := range tables {
+		w := do(t, h, table.method, table.target, nil, nil)
+		assert.Equal(t, table.code, w.Code, table.target)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No temporary files are left behind
+	assert.Len(t, entries, 4)
+}
+
+func TestHandlerErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	copyCard(t, "m1.mcd", filepath.Join(dir, "m1.mcd"))
+	copyCard(t, "m1.mcd", filepath.Join(dir, "corrupt.mcd"))
+	copyCard(t, "blank.mcd", filepath.Join(dir, "blank.mcd"))
+
+	if err := os.Mkdir(filepath.Join(dir, "dir.mcd"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Break the checksum of the header frame
+	f, err := os.OpenFile(filepath.Join(dir, "corrupt.mcd"), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.WriteAt([]byte{0xff}, 0x7f); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	h := server.New(dir)
+
+	w := do(t, h, http.MethodGet, "/cards/m1.mcd/saves/BASLUS-01040VAG1", nil, nil)
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+
+	save := w.Body.Bytes()
+
+	bad := append([]byte{}, save...)
+	bad[127] ^= 0xff
+
+	tables := map[string]struct {
+		method, target string
+		body           []byte
+		code           int
+	}{
+		"corrupt card": {
+			method: http.MethodGet,
+			target: "/cards/corrupt.mcd",
+			code:   http.StatusInternalServerError,
+		},
+		"upload to corrupt card": {
+			method: http.MethodPost,
+			target: "/cards/corrupt.mcd/saves",
+			body:   save,
+			code:   http.StatusInternalServerError,
+		},
+		"unreadable card": {
+			method: http.MethodGet,
+			target: "/cards/dir.mcd/image",
+			code:   http.StatusInternalServerError,
+		},
+		"upload too large": {
+			method: http.MethodPost,
+			target: "/cards/blank.mcd/saves",
+			body:   make([]byte, 15*8192+129),
+			code:   http.StatusRequestEntityTooLarge,
+		},
+		"upload too small": {
+			method: http.MethodPost,
+			target: "/cards/blank.mcd/saves",
+			body:   save[:128],
+			code:   http.StatusBadRequest,
+		},
+		"upload size mismatch": {
+			method: http.MethodPost,
+			target: "/cards/blank.mcd/saves",
+			body:   append(append([]byte{}, save...), make([]byte, 8192)...),
+			code:   http.StatusBadRequest,
+		},
+		"upload bad checksum": {
+			method: http.MethodPost,
+			target: "/cards/blank.mcd/saves",
+			body:   bad,
+			code:   http.StatusBadRequest,
+		},
+	}
+
+	for name, table := range tables {
+		name, table := name, table
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			w := do(t, h, table.method, table.target, table.body, nil)
+			assert.Equal(t, table.code, w.Code, w.Body.String())
+		})
+	}
+}
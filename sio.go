@@ -0,0 +1,342 @@
+package psx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// Based on http://problemkaputt.de/psx-spx.htm#controllersandmemorycards
+
+const (
+	sioAddress = 0x81
+	sioRead    = 'R'
+	sioWrite   = 'W'
+	sioGetID   = 'S'
+
+	sioHighZ   = 0xff
+	sioID1     = 0x5a
+	sioID2     = 0x5d
+	sioAck1    = 0x5c
+	sioAck2    = 0x5d
+	sioGood    = 'G'
+	sioBadSum  = 'N'
+	sioBadSect = 0xff
+
+	numSectors = cardSize / frameSize
+)
+
+// Bits of the FLAG byte returned in reply to each command.
+const (
+	// FlagError is set when the last write command failed.
+	FlagError byte = 0x04
+	// FlagNewCard is set until the first successful write command after
+	// the memory card is inserted, allowing a console to notice that it's
+	// been changed.
+	FlagNewCard byte = 0x08
+)
+
+// The replies to the get ID command following the acknowledgement.
+var sioIDReply = [...]byte{0x04, 0x00, 0x00, 0x80} //nolint:gochecknoglobals
+
+type sioState int
+
+const (
+	sioIdle sioState = iota
+	sioCommand
+	sioReadCommand
+	sioWriteCommand
+	sioGetIDCommand
+	sioDone
+)
+
+// An SIOCard emulates the memory card side of the serial protocol used by a
+// PlayStation 1 console to talk to a memory card. It understands the read,
+// write and get ID commands and is driven one byte at a time with Transfer.
+// Writes update the memory card straight away, including any directory
+// frames, so a Reader returned by Reader sees them.
+type SIOCard struct {
+	mc   *memoryCard
+	flag byte
+
+	state  sioState
+	pos    int
+	prev   byte
+	sector uint16
+	frame  [frameSize]byte
+	sum    byte
+	status byte
+}
+
+// NewSIOCard returns an SIOCard holding a copy of the memory card read by r,
+// or an empty formatted memory card if r is nil. The card starts out as if it
+// has just been inserted, with FlagNewCard set.
+func NewSIOCard(r *Reader) (*SIOCard, error) {
+	var (
+		mc  *memoryCard
+		err error
+	)
+
+	if r == nil {
+		if mc, err = newMemoryCard(); err != nil {
+			return nil, err
+		}
+	} else {
+		mc = new(memoryCard)
+		if err = mc.unmarshalBinary(io.NewSectionReader(r.ra, 0, cardSize)); err != nil {
+			return nil, err
+		}
+	}
+
+	return &SIOCard{mc: mc, flag: FlagNewCard}, nil
+}
+
+// Flag returns the current FLAG byte.
+func (c *SIOCard) Flag() byte {
+	return c.flag
+}
+
+// Reinsert emulates removing the memory card and inserting it again, which
+// ends any transaction and sets FlagNewCard.
+func (c *SIOCard) Reinsert() {
+	c.Deselect()
+	c.flag |= FlagNewCard
+}
+
+// Deselect ends any transaction in progress, which happens when the console
+// releases the memory card by raising /CS. It must be called between
+// transactions.
+func (c *SIOCard) Deselect() {
+	c.state = sioIdle
+	c.pos = 0
+}
+
+// Reader returns a Reader for the current contents of the memory card.
+func (c *SIOCard) Reader() (*Reader, error) {
+	b, err := c.mc.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReader(bytes.NewReader(b))
+}
+
+// Transfer sends the byte b from the console to the memory card and returns
+// the byte sent back at the same time. The returned bool reports whether the
+// memory card pulses /ACK afterwards, which means it expects another byte.
+//
+//nolint:cyclop
+func (c *SIOCard) Transfer(b byte) (byte, bool) {
+	reply, ack := byte(sioHighZ), true
+
+	switch c.state {
+	case sioIdle:
+		// Anything other than the memory card address is for another
+		// device, such as a controller
+		if b == sioAddress {
+			c.state = sioCommand
+		} else {
+			c.state, ack = sioDone, false
+		}
+	case sioCommand:
+		reply = c.flag
+
+		switch b {
+		case sioRead:
+			c.state = sioReadCommand
+		case sioWrite:
+			c.state = sioWriteCommand
+		case sioGetID:
+			c.state = sioGetIDCommand
+		default:
+			c.state, ack = sioDone, false
+		}
+	case sioReadCommand:
+		reply, ack = c.read(b)
+	case sioWriteCommand:
+		reply, ack = c.write(b)
+	case sioGetIDCommand:
+		reply, ack = c.getID()
+	case sioDone:
+		ack = false
+	}
+
+	if !ack {
+		c.state = sioDone
+	}
+
+	c.pos++
+	c.prev = b
+
+	return reply, ack
+}
+
+// address handles the common start of the read and write commands, returning
+// true if b was part of it.
+func (c *SIOCard) address(b byte) (byte, bool) {
+	switch c.pos {
+	case 2: //nolint:gomnd
+		return sioID1, true
+	case 3: //nolint:gomnd
+		return sioID2, true
+	case 4: //nolint:gomnd
+		c.sector = uint16(b) << 8 //nolint:gomnd
+
+		return 0x00, true
+	case 5: //nolint:gomnd
+		c.sector |= uint16(b)
+		c.sum = byte(c.sector>>8) ^ b //nolint:gomnd
+
+		return c.prev, true
+	}
+
+	return 0, false
+}
+
+// Byte positions within the read command after the address.
+const (
+	readAck1 = iota + 6
+	readAck2
+	readMSB
+	readLSB
+	readData
+	readChecksum = readData + frameSize
+)
+
+func (c *SIOCard) read(b byte) (byte, bool) {
+	if reply, ok := c.address(b); ok {
+		return reply, true
+	}
+
+	valid := c.sector < numSectors
+
+	switch {
+	case c.pos == readAck1:
+		return sioAck1, true
+	case c.pos == readAck2:
+		if valid {
+			c.readFrame(int(c.sector), c.frame[:])
+		}
+
+		return sioAck2, true
+	case !valid:
+		// An invalid sector is confirmed as 0xffff and nothing follows
+		return sioHighZ, c.pos == readMSB
+	case c.pos == readMSB:
+		return byte(c.sector >> 8), true //nolint:gomnd
+	case c.pos == readLSB:
+		return byte(c.sector), true
+	case c.pos < readChecksum:
+		reply := c.frame[c.pos-readData]
+		c.sum ^= reply
+
+		return reply, true
+	case c.pos == readChecksum:
+		return c.sum, true
+	}
+
+	return sioGood, false
+}
+
+// Byte positions within the write command after the address.
+const (
+	writeData     = 6
+	writeChecksum = writeData + frameSize
+	writeAck1     = writeChecksum + 1
+	writeAck2     = writeAck1 + 1
+)
+
+func (c *SIOCard) write(b byte) (byte, bool) {
+	if reply, ok := c.address(b); ok {
+		return reply, true
+	}
+
+	switch {
+	case c.pos < writeChecksum:
+		c.frame[c.pos-writeData] = b
+		c.sum ^= b
+
+		return c.prev, true
+	case c.pos == writeChecksum:
+		switch {
+		case c.sector >= numSectors:
+			c.status = sioBadSect
+		case b != c.sum:
+			c.status = sioBadSum
+		default:
+			c.status = sioGood
+			c.writeFrame(int(c.sector), c.frame[:])
+		}
+
+		if c.status == sioGood {
+			c.flag &^= FlagNewCard | FlagError
+		} else {
+			c.flag |= FlagError
+		}
+
+		return c.prev, true
+	case c.pos == writeAck1:
+		return sioAck1, true
+	case c.pos == writeAck2:
+		return sioAck2, true
+	}
+
+	return c.status, false
+}
+
+func (c *SIOCard) getID() (byte, bool) {
+	switch c.pos {
+	case 2: //nolint:gomnd
+		return sioID1, true
+	case 3: //nolint:gomnd
+		return sioID2, true
+	case 4: //nolint:gomnd
+		return sioAck1, true
+	case 5: //nolint:gomnd
+		return sioAck2, true
+	}
+
+	i := c.pos - 6 //nolint:gomnd
+
+	return sioIDReply[i], i < len(sioIDReply)-1
+}
+
+// readFrame copies sector i of the memory card into b.
+func (c *SIOCard) readFrame(i int, b []byte) {
+	if i >= blockSize/frameSize {
+		block := i/(blockSize/frameSize) - reservedBlocks
+		copy(b, c.mc.DataBlock[block][i%(blockSize/frameSize)*frameSize:])
+
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Grow(blockSize)
+
+	_ = binary.Write(buf, binary.LittleEndian, &c.mc.HeaderBlock)
+
+	copy(b, buf.Bytes()[i*frameSize:])
+}
+
+// writeFrame replaces sector i of the memory card with b. Frames in the
+// header block are decoded as they would be when reading a memory card, but
+// are kept even if they're invalid as a real memory card doesn't check them.
+func (c *SIOCard) writeFrame(i int, b []byte) {
+	hb := &c.mc.HeaderBlock
+
+	switch {
+	case i >= blockSize/frameSize:
+		block := i/(blockSize/frameSize) - reservedBlocks
+		copy(c.mc.DataBlock[block][i%(blockSize/frameSize)*frameSize:], b)
+	case i == 0:
+		_ = hb.HeaderFrame.decode(b)
+	case i < firstUnusedFrame:
+		_ = hb.DirectoryFrame[i-firstDirectoryFrame].decode(b)
+	case i < firstUnusedFrame+numUnusedFrames:
+		hb.UnusedFrame[i-firstUnusedFrame].decode(b)
+	case i < trailingFrame:
+		copy(hb.Reserved[(i-firstUnusedFrame-numUnusedFrames)*frameSize:], b)
+	default:
+		_ = hb.TrailingFrame.decode(b)
+	}
+}
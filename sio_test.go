@@ -0,0 +1,147 @@
+package psx_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bodgit/psx"
+	"github.com/bodgit/psx/internal/xor"
+	"github.com/stretchr/testify/assert"
+)
+
+// transaction sends each byte in the slice to c and returns the replies and
+// the number of bytes that were acknowledged, before deselecting the card.
+func transaction(c *psx.SIOCard, in []byte) ([]byte, int) {
+	defer c.Deselect()
+
+	out := make([]byte, 0, len(in))
+	acks := 0
+
+	for _, b := range in {
+		reply, ack := c.Transfer(b)
+		out = append(out, reply)
+
+		if ack {
+			acks++
+		}
+	}
+
+	return out, acks
+}
+
+func readSector(sector uint16) []byte {
+	in := make([]byte, 140)
+	in[0], in[1] = 0x81, 'R'
+	in[4], in[5] = byte(sector>>8), byte(sector)
+
+	return in
+}
+
+func writeSector(sector uint16, frame []byte, checksum byte) []byte {
+	in := []byte{0x81, 'W', 0x00, 0x00, byte(sector >> 8), byte(sector)}
+	in = append(in, frame...)
+
+	return append(in, checksum, 0x00, 0x00, 0x00)
+}
+
+func TestSIOCardGetID(t *testing.T) {
+	t.Parallel()
+
+	c, err := psx.NewSIOCard(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, acks := transaction(c, []byte{0x81, 'S', 0, 0, 0, 0, 0, 0, 0, 0})
+	assert.Equal(t, []byte{0xff, psx.FlagNewCard, 0x5a, 0x5d, 0x5c, 0x5d, 0x04, 0x00, 0x00, 0x80}, out)
+	assert.Equal(t, 9, acks)
+
+	// A controller address is ignored, as is anything sent afterwards
+	out, acks = transaction(c, []byte{0x01, 0x42, 0x00})
+	assert.Equal(t, []byte{0xff, 0xff, 0xff}, out)
+	assert.Equal(t, 0, acks)
+
+	out, acks = transaction(c, []byte{0x81, 'X', 0x00})
+	assert.Equal(t, []byte{0xff, psx.FlagNewCard, 0xff}, out)
+	assert.Equal(t, 1, acks)
+}
+
+//nolint:funlen
+func TestSIOCardReadWrite(t *testing.T) {
+	t.Parallel()
+
+	rc, err := psx.OpenReader(filepath.Join("testdata", "m1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	c, err := psx.NewSIOCard(&rc.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []uint16{0, 1, 63, 64, 0x3ff}
+
+	for _, sector := range tables {
+		frame, err := rc.Frame(int(sector))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out, acks := transaction(c, readSector(sector))
+		assert.Equal(t, []byte{0xff, psx.FlagNewCard, 0x5a, 0x5d, 0x00, byte(sector >> 8)}, out[:6])
+		assert.Equal(t, []byte{0x5c, 0x5d, byte(sector >> 8), byte(sector)}, out[6:10])
+		assert.Equal(t, frame, out[10:138])
+		assert.Equal(t, byte(sector>>8)^byte(sector)^xor.Checksum(frame), out[138])
+		assert.Equal(t, byte('G'), out[139])
+		assert.Equal(t, 139, acks)
+	}
+
+	// An invalid sector is confirmed as 0xffff and the transaction stops
+	out, acks := transaction(c, readSector(0x400))
+	assert.Equal(t, []byte{0xff, 0xff}, out[8:10])
+	assert.Equal(t, 9, acks)
+
+	// Rename the first file by rewriting its directory frame
+	frame, err := rc.Frame(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copy(frame[0x16:0x1e], "RENAMED\x00")
+	frame[127] = xor.Checksum(frame[:127])
+
+	// A bad checksum is rejected and sets the error flag
+	out, acks = transaction(c, writeSector(1, frame, 0x00))
+	assert.Equal(t, []byte{0x5c, 0x5d, 'N'}, out[135:])
+	assert.Equal(t, 137, acks)
+	assert.Equal(t, psx.FlagNewCard|psx.FlagError, c.Flag())
+
+	out, _ = transaction(c, writeSector(0x400, frame, 0x04^xor.Checksum(frame)))
+	assert.Equal(t, byte(0xff), out[137])
+
+	out, acks = transaction(c, writeSector(1, frame, 0x01^xor.Checksum(frame)))
+	assert.Equal(t, byte(1), out[6])
+	assert.Equal(t, frame[127], out[134])
+	assert.Equal(t, []byte{0x5c, 0x5d, 'G'}, out[135:])
+	assert.Equal(t, 137, acks)
+	assert.Equal(t, byte(0), c.Flag())
+
+	r, err := c.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, r.File, len(rc.File)) {
+		assert.Equal(t, "BISLPS-00093RENAMED", r.File[0].Name)
+		assert.Equal(t, rc.File[1].Name, r.File[1].Name)
+	}
+
+	out, _ = transaction(c, readSector(1))
+	assert.Equal(t, byte(0), out[1])
+	assert.Equal(t, frame, out[10:138])
+
+	c.Reinsert()
+	assert.Equal(t, psx.FlagNewCard, c.Flag())
+}
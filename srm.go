@@ -0,0 +1,37 @@
+package psx
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/bodgit/psx/internal/xor"
+)
+
+// srmHeader returns whatever came before the memory card when it was read,
+// as there's no way to recreate it.
+func srmHeader(_, prev []byte) ([]byte, error) {
+	return append([]byte{}, prev...), nil
+}
+
+// locateSRM finds the first memory card within the image of size bytes read
+// by r, provided it contains more than just a memory card. The memory card
+// may be preceded by extra data of any size so each frame is searched for a
+// valid header frame.
+func locateSRM(r io.ReaderAt, size int64, buf []byte) (int64, bool, error) {
+	if size <= cardSize {
+		return 0, false, nil
+	}
+
+	for i := int64(0); i+cardSize <= size; i += frameSize {
+		if _, err := r.ReadAt(buf, i); err != nil {
+			return 0, false, fmt.Errorf("unable to read frame: %w", err)
+		}
+
+		if bytes.HasPrefix(buf, headerSignature[:]) && xor.Checksum(buf[:frameSize-1]) == buf[frameSize-1] {
+			return i, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
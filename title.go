@@ -0,0 +1,113 @@
+package psx
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+// The first block of each file starts with a title frame, followed by one to
+// three icon frames.
+const (
+	iconDisplayOffset = 0x02
+	titleOffset       = 0x04
+	titleSize         = 0x40
+	paletteOffset     = 0x60
+	paletteSize       = 0x20
+	iconOffset        = 0x80
+	iconFrameSize     = frameSize
+)
+
+func iconFrames(display byte) int {
+	switch display {
+	case 0x11, 0x12, 0x13:
+		return int(display - 0x10)
+	default:
+		return 0
+	}
+}
+
+func titleBytes(b []byte) []byte {
+	return b[titleOffset : titleOffset+titleSize]
+}
+
+// iconBytes returns the icon display flag, palette and icon frames.
+func iconBytes(b []byte) []byte {
+	n := iconFrames(b[iconDisplayOffset])
+
+	icon := make([]byte, 0, 1+paletteSize+n*iconFrameSize)
+	icon = append(icon, b[iconDisplayOffset])
+	icon = append(icon, b[paletteOffset:paletteOffset+paletteSize]...)
+
+	return append(icon, b[iconOffset:iconOffset+n*iconFrameSize]...)
+}
+
+// fullWidth maps the full-width forms of ASCII characters commonly used in
+// titles to their usual forms.
+func fullWidth(r rune) rune {
+	switch {
+	case r == '\u3000':
+		return ' '
+	case r == '\u301c':
+		// The full-width tilde decodes as a wave dash
+		return '~'
+	case r >= '\uff01' && r <= '\uff5e':
+		return r - '\uff01' + '!'
+	default:
+		return r
+	}
+}
+
+// toFullWidth is the inverse of fullWidth.
+func toFullWidth(r rune) rune {
+	switch {
+	case r == ' ':
+		return '\u3000'
+	case r >= '!' && r <= '~':
+		return r - '!' + '\uff01'
+	default:
+		return r
+	}
+}
+
+// encodeTitle encodes s as Shift-JIS, replacing any ASCII characters with
+// their full-width forms as the BIOS expects.
+func encodeTitle(s string) ([]byte, error) {
+	b, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte(strings.Map(toFullWidth, s)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode title: %w", err)
+	}
+
+	if len(b) > titleSize {
+		return nil, fmt.Errorf("title is %d bytes: %w", len(b), ErrInvalidLength)
+	}
+
+	return b, nil
+}
+
+func decodeTitle(b []byte) (string, error) {
+	s, err := japanese.ShiftJIS.NewDecoder().Bytes(cbytes(titleBytes(b)))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(strings.Map(fullWidth, string(s))), nil
+}
+
+// Title returns the title of the file shown by the memory card manager,
+// decoded from Shift-JIS. Full-width letters, digits and punctuation are
+// replaced with their ASCII equivalents.
+func (f *File) Title() (string, error) {
+	b, err := f.r.block(f.i)
+	if err != nil {
+		return "", err
+	}
+
+	s, err := decodeTitle(b)
+	if err != nil {
+		return "", fmt.Errorf("unable to decode title: %w", err)
+	}
+
+	return s, nil
+}
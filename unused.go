@@ -1,11 +1,15 @@
 package psx
 
+import "encoding/binary"
+
+const noBrokenSector = 0xffffffff
+
 type unusedFrame struct {
 	AvailableBlocks byte
 	Reserved        [3]byte
-	_               [4]byte
+	Unknown         [4]byte
 	LinkOrder       uint16
-	_               [118]byte
+	Padding         [118]byte
 }
 
 func newUnusedFrame() unusedFrame {
@@ -15,3 +19,21 @@ func newUnusedFrame() unusedFrame {
 		LinkOrder:       lastLink,
 	}
 }
+
+// brokenSector returns the number of the broken sector recorded in the frame,
+// if any.
+func (uf *unusedFrame) brokenSector() (uint32, bool) {
+	b := [4]byte{uf.AvailableBlocks, uf.Reserved[0], uf.Reserved[1], uf.Reserved[2]}
+	sector := binary.LittleEndian.Uint32(b[:])
+
+	return sector, sector != noBrokenSector
+}
+
+// decode decodes the unused frame from the frame b.
+func (uf *unusedFrame) decode(b []byte) {
+	uf.AvailableBlocks = b[0]
+	copy(uf.Reserved[:], b[1:])
+	copy(uf.Unknown[:], b[4:])
+	uf.LinkOrder = binary.LittleEndian.Uint16(b[8:])
+	copy(uf.Padding[:], b[10:])
+}
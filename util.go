@@ -2,20 +2,38 @@ package psx
 
 import (
 	"bytes"
-	"hash"
-	"io"
 
 	"github.com/bodgit/psx/internal/xor"
 )
 
 func checksum(b []byte) []byte {
-	h := xor.New()
+	return []byte{xor.Checksum(b)}
+}
 
-	_, _ = io.Copy(h, bytes.NewReader(b))
+// verifyChecksum checks the checksum stored in the last byte of frame b,
+// returning a *ChecksumError wrapping err if it doesn't match.
+func verifyChecksum(b []byte, err error) error {
+	if sum := xor.Checksum(b[:frameSize-1]); sum != b[frameSize-1] {
+		return &ChecksumError{Expected: sum, Actual: b[frameSize-1], Err: err}
+	}
 
-	return h.Sum(nil)
+	return nil
 }
 
-func checksumReader(r io.Reader, h hash.Hash, size int) io.Reader {
-	return io.MultiReader(io.TeeReader(io.LimitReader(r, int64(size)-1), h), r)
+// cstring returns the contents of b up to the first NUL byte.
+func cstring(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+
+	return string(b)
+}
+
+// cbytes is like cstring but returns a slice of b.
+func cbytes(b []byte) []byte {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		return b[:i]
+	}
+
+	return b
 }
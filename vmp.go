@@ -0,0 +1,78 @@
+package psx
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec
+	"encoding/binary"
+)
+
+const (
+	vmpHeaderSize      = 0x80
+	vmpSaltSeedOffset  = 0x0c
+	vmpSignatureOffset = 0x20
+)
+
+//nolint:gochecknoglobals
+var (
+	vmpSignature = [4]byte{0x00, 'P', 'M', 'V'}
+
+	vmpKey = [aes.BlockSize]byte{
+		0xab, 0x5a, 0xbc, 0x9f, 0xc1, 0xf4, 0x9d, 0xe6,
+		0xa0, 0x51, 0xdb, 0xae, 0xfa, 0x51, 0x88, 0x59,
+	}
+	vmpIV = [aes.BlockSize]byte{
+		0xb3, 0x0f, 0xfe, 0xed, 0xb7, 0xdc, 0x5e, 0xb7,
+		0x13, 0x3d, 0xa6, 0x0d, 0x1b, 0x6b, 0x2c, 0xdc,
+	}
+)
+
+func detectVMP(b []byte) bool {
+	return bytes.HasPrefix(b, vmpSignature[:])
+}
+
+// vmpSign computes the HMAC-SHA1 signature over the whole file b, with the
+// key derived from the salt seed stored in the header.
+func vmpSign(b []byte) []byte {
+	c, _ := aes.NewCipher(vmpKey[:])
+
+	seed := b[vmpSaltSeedOffset : vmpSaltSeedOffset+sha1.Size]
+	key := make([]byte, sha1.Size)
+
+	c.Decrypt(key, seed[:aes.BlockSize])
+
+	for i := range vmpIV {
+		key[i] ^= vmpIV[i]
+	}
+
+	buf := make([]byte, aes.BlockSize)
+	c.Encrypt(buf, seed[:aes.BlockSize])
+
+	for i := aes.BlockSize; i < sha1.Size; i++ {
+		key[i] = buf[i-aes.BlockSize] ^ seed[i]
+	}
+
+	mac := hmac.New(sha1.New, key)
+
+	_, _ = mac.Write(b[:vmpSignatureOffset])
+	_, _ = mac.Write(make([]byte, sha1.Size))
+	_, _ = mac.Write(b[vmpSignatureOffset+sha1.Size:])
+
+	return mac.Sum(nil)
+}
+
+// vmpHeader generates the header prepended to the memory card image. The
+// salt seed is left zeroed.
+func vmpHeader(b, _ []byte) ([]byte, error) {
+	buf := make([]byte, vmpHeaderSize, vmpHeaderSize+len(b))
+
+	copy(buf, vmpSignature[:])
+	binary.LittleEndian.PutUint32(buf[4:], vmpHeaderSize)
+
+	buf = append(buf, b...)
+
+	copy(buf[vmpSignatureOffset:], vmpSign(buf))
+
+	return buf[:vmpHeaderSize], nil
+}
@@ -2,22 +2,18 @@ package psx
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"sync"
 )
 
-var (
-	errDuplicateName = errors.New("duplicate name")
-	errInvalidLength = errors.New("invalid length")
-	errNoFreeSpace   = errors.New("no free space")
-)
-
 type fileWriter struct {
-	buf *bytes.Buffer
-	w   *Writer
+	buf     *bytes.Buffer
+	w       *Writer
+	replace string
 }
 
 func (w *fileWriter) maxSize() int {
@@ -27,17 +23,21 @@ func (w *fileWriter) maxSize() int {
 func (w *fileWriter) Write(p []byte) (int, error) {
 	if len(p)+w.buf.Len() > w.maxSize() {
 		// Would exceed the maximum size
-		return 0, errInvalidLength
+		return 0, &LengthError{Expected: int64(w.maxSize()), Actual: int64(len(p) + w.buf.Len())}
 	}
 
 	return w.buf.Write(p) //nolint:wrapcheck
 }
 
-//nolint:cyclop,funlen
 func (w *fileWriter) Close() error {
 	w.w.mu.Lock()
 	defer w.w.mu.Unlock()
 
+	return w.close()
+}
+
+//nolint:cyclop,funlen
+func (w *fileWriter) close() error {
 	delete(w.w.fw, w)
 
 	mc := w.w.mc
@@ -47,36 +47,62 @@ func (w *fileWriter) Close() error {
 		return fmt.Errorf("unable to read header: %w", err)
 	}
 
-	for _, x := range mc.HeaderBlock.DirectoryFrame {
-		if !x.isFirst() {
+	// The blocks of a file being replaced are reused first
+	var old []int
+
+	if w.replace != "" {
+		i, ok := mc.HeaderBlock.find(w.replace)
+		if !ok {
+			return &FileError{Name: w.replace, Err: fs.ErrNotExist}
+		}
+
+		var err error
+		if old, err = mc.HeaderBlock.chain(i); err != nil {
+			return err
+		}
+	}
+
+	for i, x := range mc.HeaderBlock.DirectoryFrame {
+		if !x.isFirst() || len(old) > 0 && i == old[0] {
 			continue
 		}
 
 		if x.filename() == df.filename() {
-			return errDuplicateName
+			return &FileError{Name: df.filename(), Err: ErrDuplicateName}
 		}
 	}
 
 	if w.buf.Len()%blockSize != 0 || w.buf.Len() != int(df.Size) {
-		return errInvalidLength
+		return &LengthError{Name: df.filename(), Expected: int64(df.Size), Actual: int64(w.buf.Len())}
 	}
 
 	blocks := w.buf.Len() / blockSize
 
-	if w.w.i+blocks > numBlocks {
-		return errNoFreeSpace
+	free := append(old, mc.HeaderBlock.free()...)
+	if blocks > len(free) {
+		return &SpaceError{Name: df.filename(), Required: blocks, Available: len(free)}
 	}
 
-	for i := 0; i < blocks; i++ {
-		frame := w.w.i + i
+	// Any blocks of the replaced file that aren't needed become free
+	if len(old) > blocks {
+		for _, i := range old[blocks:] {
+			mc.HeaderBlock.DirectoryFrame[i] = newDirectoryFrame()
+		}
+	}
 
+	free = free[:blocks]
+
+	for i, frame := range free {
 		if i == 0 {
 			mc.HeaderBlock.DirectoryFrame[frame] = *df
+		} else {
+			// Don't leave anything behind from a deleted file
+			mc.HeaderBlock.DirectoryFrame[frame] = newDirectoryFrame()
 		}
 
-		lo := uint16(frame + 1)
-		if i+1 == blocks {
-			lo = lastLink
+		lo := uint16(lastLink)
+		if i+1 < blocks {
+			lo = uint16(free[i+1])
 		}
 
 		ab := blockMiddleLink
@@ -94,19 +120,24 @@ func (w *fileWriter) Close() error {
 		}
 	}
 
-	w.w.i += blocks
-
 	return mc.checksum()
 }
 
 // A Writer is used for creating a new memory card image with files written to
 // it.
 type Writer struct {
-	mu sync.Mutex
-	w  io.Writer
-	mc *memoryCard
-	fw map[*fileWriter]struct{}
-	i  int
+	mu     sync.Mutex
+	w      io.Writer
+	mc     *memoryCard
+	fw     map[*fileWriter]struct{}
+	format Format
+	prev   []byte
+	next   []byte
+
+	file    *atomicFile
+	offset  int64
+	started bool
+	closed  bool
 }
 
 // Create returns an io.WriteCloser for writing a new file on the memory card.
@@ -116,56 +147,299 @@ func (w *Writer) Create() (io.WriteCloser, error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	if w.i == numBlocks {
-		return nil, errNoFreeSpace
+	if w.closed {
+		return nil, ErrClosed
+	}
+
+	if len(w.mc.HeaderBlock.free()) == 0 {
+		return nil, &SpaceError{Required: 1}
 	}
 
-	fw := &fileWriter{new(bytes.Buffer), w}
+	fw := &fileWriter{buf: new(bytes.Buffer), w: w}
 	w.fw[fw] = struct{}{}
 
 	return fw, nil
 }
 
-// Close writes out the memory card to the underlying io.Writer. Any in-flight
-// open memory card files are closed first.
-func (w *Writer) Close() error {
+// Replace is like Create but the new file replaces the named file already on
+// the memory card once it's closed, reusing the same blocks where possible.
+// The new file may have the same name as the file it replaces.
+func (w *Writer) Replace(name string) (io.WriteCloser, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil, ErrClosed
+	}
+
+	if _, ok := w.mc.HeaderBlock.find(name); !ok {
+		return nil, &FileError{Name: name, Err: fs.ErrNotExist}
+	}
+
+	fw := &fileWriter{buf: new(bytes.Buffer), w: w, replace: name}
+	w.fw[fw] = struct{}{}
+
+	return fw, nil
+}
+
+// Remove deletes the named file from the memory card. As with the memory card
+// manager, the blocks are only marked as deleted so the file can still be
+// recovered until its blocks are needed for another file.
+func (w *Writer) Remove(name string) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	if w.closed {
+		return ErrClosed
+	}
+
+	i, ok := w.mc.HeaderBlock.find(name)
+	if !ok {
+		return &FileError{Name: name, Err: fs.ErrNotExist}
+	}
+
+	chain, err := w.mc.HeaderBlock.chain(i)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range chain {
+		df := &w.mc.HeaderBlock.DirectoryFrame[i]
+		df.AvailableBlocks += blockDeletedFirstLink - blockFirstLink
+	}
+
+	return w.mc.checksum()
+}
+
+// writeContext writes b to w a block at a time, stopping if ctx is cancelled.
+func writeContext(ctx context.Context, w io.Writer, b []byte) error {
+	for len(b) > 0 {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("unable to write memory card: %w", err)
+		}
+
+		n := blockSize
+		if n > len(b) {
+			n = len(b)
+		}
+
+		if m, err := w.Write(b[:n]); err != nil || m != n {
+			if err != nil {
+				return fmt.Errorf("unable to write memory card: %w", err)
+			}
+
+			return ErrInvalidLength
+		}
+
+		b = b[n:]
+	}
+
+	return nil
+}
+
+func (w *Writer) flush(ctx context.Context) error {
 	for fw := range w.fw {
-		if err := fw.Close(); err != nil {
+		if err := fw.close(); err != nil {
 			return err
 		}
-
-		delete(w.fw, fw)
 	}
 
-	b, err := w.mc.MarshalBinary()
+	b, err := w.format.marshalBinary(w.mc, w.prev, w.next)
 	if err != nil {
 		return err
 	}
 
-	if n, err := w.w.Write(b); err != nil || n != w.mc.size() {
+	// Rewind to where the memory card was first written, if possible. The
+	// position is recorded before the first attempt so that a write that
+	// fails partway is overwritten rather than appended to
+	if s, ok := w.w.(io.Seeker); ok {
+		if !w.started {
+			if w.offset, err = s.Seek(0, io.SeekCurrent); err != nil {
+				return fmt.Errorf("unable to seek: %w", err)
+			}
+
+			w.started = true
+		}
+
+		if _, err := s.Seek(w.offset, io.SeekStart); err != nil {
+			return fmt.Errorf("unable to seek: %w", err)
+		}
+	}
+
+	return writeContext(ctx, w.w, b)
+}
+
+// FlushContext closes any in-flight open memory card files and writes out the
+// memory card to the underlying io.Writer, stopping if ctx is cancelled. If
+// the io.Writer is also an io.Seeker then the memory card is rewritten in the
+// same place each time, otherwise another copy is written.
+func (w *Writer) FlushContext(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+
+	return w.flush(ctx)
+}
+
+// CloseContext is like Close but stops if ctx is cancelled. If the Writer was
+// created with CreateFile, the file is left untouched unless the memory card
+// is written out completely, so closing with an already cancelled context
+// abandons any changes.
+func (w *Writer) CloseContext(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+
+	w.closed = true
+
+	err := w.flush(ctx)
+
+	if w.file != nil {
 		if err != nil {
-			return fmt.Errorf("unable to write memory card: %w", err)
+			w.file.abort()
+
+			return err
 		}
 
-		return errInvalidLength
+		return w.file.commit()
 	}
 
-	return nil
+	return err
+}
+
+// abort abandons any changes, as with a cancelled CloseContext.
+func (w *Writer) abort() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_ = w.CloseContext(ctx)
+}
+
+// Close writes out the memory card to the underlying io.Writer. Any in-flight
+// open memory card files are closed first.
+func (w *Writer) Close() error {
+	return w.CloseContext(context.Background())
 }
 
-// NewWriter returns a Writer that will write a new memory card to w.
+// NewWriter returns a Writer that will write a new raw memory card to w.
 func NewWriter(w io.Writer) (*Writer, error) {
+	return NewWriterWithFormat(w, FormatRaw)
+}
+
+// NewWriterWithFormat returns a Writer that will write a new memory card to w
+// using format f. ErrUnsupportedFormat is returned if f can only be read.
+func NewWriterWithFormat(w io.Writer, f Format) (*Writer, error) {
+	if err := f.writable(); err != nil {
+		return nil, err
+	}
+
 	mc, err := newMemoryCard()
 	if err != nil {
 		return nil, err
 	}
 
 	return &Writer{
-		w:  w,
-		mc: mc,
-		fw: make(map[*fileWriter]struct{}),
+		w:      w,
+		mc:     mc,
+		fw:     make(map[*fileWriter]struct{}),
+		format: f,
+	}, nil
+}
+
+// NewWriterFrom returns a Writer that will write a copy of the memory card
+// read by r to w using the same format. Unlike copying each file onto a new
+// memory card, everything is kept as-is, including deleted files, the list of
+// broken sectors, any unused bytes in the header block and anything else
+// stored in the format's own header, such as DexDrive comments, or around
+// the memory card in a FormatSRM image. New files are written to free
+// blocks, only reusing the blocks of deleted files once there are no free
+// blocks left.
+func NewWriterFrom(w io.Writer, r *Reader) (*Writer, error) {
+	if err := r.format.writable(); err != nil {
+		return nil, err
+	}
+
+	mc := &memoryCard{HeaderBlock: *r.hb}
+
+	for i := range mc.DataBlock {
+		b, err := r.block(i)
+		if err != nil {
+			return nil, err
+		}
+
+		copy(mc.DataBlock[i][:], b)
+	}
+
+	return &Writer{
+		w:      w,
+		mc:     mc,
+		fw:     make(map[*fileWriter]struct{}),
+		format: r.format,
+		prev:   r.prev,
+		next:   r.next,
 	}, nil
 }
+
+// CreateFile returns a Writer that will write a new memory card to the file
+// specified by name using format f. The memory card is written to a temporary
+// file in the same directory which is synced to disk and only replaces the
+// named file once the Writer has been successfully closed.
+func CreateFile(name string, f Format, opts ...FileOption) (*Writer, error) {
+	if err := f.writable(); err != nil {
+		return nil, err
+	}
+
+	af, err := createAtomic(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := NewWriterWithFormat(af, f)
+	if err != nil {
+		af.abort()
+
+		return nil, err
+	}
+
+	w.file = af
+
+	return w, nil
+}
+
+// OpenForUpdate is like CreateFile but the Writer starts with a copy of the
+// existing memory card in the file specified by name, as with NewWriterFrom,
+// and uses the same format. More files can then be added before the Writer
+// is closed.
+func OpenForUpdate(name string, opts ...FileOption) (*Writer, error) {
+	rc, err := OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	if err := rc.Format().writable(); err != nil {
+		return nil, fmt.Errorf("unable to update: %w", err)
+	}
+
+	af, err := createAtomic(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := NewWriterFrom(af, &rc.Reader)
+	if err != nil {
+		af.abort()
+
+		return nil, err
+	}
+
+	w.file = af
+
+	return w, nil
+}
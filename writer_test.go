@@ -2,8 +2,10 @@ package psx_test
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"testing"
@@ -67,6 +69,212 @@ func TestCopy(t *testing.T) {
 	assert.Nil(t, fw.Close())
 }
 
+func TestCreateFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "card.mcd")
+
+	if err := os.WriteFile(name, []byte("original"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	save := newSave("BESLES-00000SAVE", 1)
+
+	w, err := psx.CreateFile(name, psx.FormatRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fw, err := w.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write(save); err != nil {
+		t.Fatal(err)
+	}
+
+	// A cancelled close leaves the original file alone
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, w.CloseContext(ctx), context.Canceled)
+	assert.ErrorIs(t, w.Close(), psx.ErrClosed)
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []byte("original"), b)
+
+	// As does failing to close a file
+	w, err = psx.CreateFile(name, psx.FormatRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fw, err = w.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write(save[:frameSize]); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.ErrorIs(t, w.Close(), psx.ErrInvalidLength)
+
+	b, err = os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []byte("original"), b)
+
+	w, err = psx.CreateFile(name, psx.FormatRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, entries, 1)
+
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, int64(131072), fi.Size())
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+}
+
+func TestFlushContext(t *testing.T) {
+	t.Parallel()
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "card.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w, err := psx.NewWriter(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.FlushContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	fw, err := w.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write(newSave("BESLES-00000SAVE", 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flushing closes the file and rewrites the memory card in place
+	if err := w.FlushContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = w.Create()
+	assert.ErrorIs(t, err, psx.ErrClosed)
+
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, int64(131072), fi.Size())
+
+	rc, err := psx.OpenReader(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	assert.Len(t, rc.File, 1)
+}
+
+// cancelFile cancels a context after each write.
+type cancelFile struct {
+	*os.File
+	cancel context.CancelFunc
+}
+
+func (f *cancelFile) Write(p []byte) (int, error) {
+	defer f.cancel()
+
+	return f.File.Write(p) //nolint:wrapcheck
+}
+
+// A flush that is cancelled partway is overwritten when retried.
+func TestFlushContextRetry(t *testing.T) {
+	t.Parallel()
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "card.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w, err := psx.NewWriter(&cancelFile{f, cancel})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.ErrorIs(t, w.FlushContext(ctx), context.Canceled)
+
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Less(t, fi.Size(), int64(131072))
+
+	if err := w.FlushContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if fi, err = f.Stat(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, int64(131072), fi.Size())
+
+	rc, err := psx.OpenReader(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	assert.Empty(t, rc.File)
+}
+
 func ExampleWriter() {
 	buf := new(bytes.Buffer)
 
@@ -82,3 +290,317 @@ func ExampleWriter() {
 	fmt.Println(buf.Len())
 	// Output: 131072
 }
+
+//nolint:funlen
+func TestOpenForUpdate(t *testing.T) {
+	t.Parallel()
+
+	b, err := os.ReadFile(filepath.Join("testdata", "m1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gme := new(bytes.Buffer)
+	if err := psx.Convert(gme, psx.FormatDexDrive, bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a comment for the first and last blocks
+	copy(gme.Bytes()[0x40:], "Comment for the first block")
+	copy(gme.Bytes()[0x40+14*0x100:], "Comment for the last block")
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "card.gme")
+
+	if err := os.WriteFile(name, gme.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := psx.OpenForUpdate(name, psx.WithBackup())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fw, err := w.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write(newSave("BESLES-00000SAVE", 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := psx.OpenReader(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	assert.Equal(t, psx.FormatDexDrive, rc.Format())
+	assert.Len(t, rc.File, 11)
+
+	updated, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The comments and the existing files are untouched
+	assert.Equal(t, gme.Bytes()[0x40:0xf40], updated[0x40:0xf40])
+	assert.Equal(t, gme.Bytes()[0xf40+frameSize:0xf40+15*frameSize], updated[0xf40+frameSize:0xf40+15*frameSize])
+	assert.Equal(t, gme.Bytes()[0xf40+blockSize:0xf40+15*blockSize], updated[0xf40+blockSize:0xf40+15*blockSize])
+
+	backup, err := os.ReadFile(name + ".bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, gme.Bytes(), backup)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, entries, 2)
+
+	// Anything else in a RetroArch save file is kept
+	prefix, suffix := bytes.Repeat([]byte{0xa5}, 0x200), bytes.Repeat([]byte{0x5a}, 0x80)
+	srm := filepath.Join(dir, "card.srm")
+
+	if err := os.WriteFile(srm, bytes.Join([][]byte{prefix, b, suffix}, nil), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if w, err = psx.OpenForUpdate(srm); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeSave(w, newSave("BESLES-00000SAVE", 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if updated, err = os.ReadFile(srm); err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, updated, len(prefix)+len(b)+len(suffix)) {
+		assert.Equal(t, prefix, updated[:len(prefix)])
+		assert.Equal(t, suffix, updated[len(prefix)+len(b):])
+	}
+
+	srmReader, err := psx.OpenReader(srm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srmReader.Close()
+
+	assert.Equal(t, psx.FormatSRM, srmReader.Format())
+	assert.Len(t, srmReader.File, 11)
+}
+
+func TestNewWriterFrom(t *testing.T) {
+	t.Parallel()
+
+	files, err := filepath.Glob(filepath.Join("testdata", "*.mc?"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		file := file
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			t.Parallel()
+
+			b, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := psx.NewReader(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			buf := new(bytes.Buffer)
+
+			w, err := psx.NewWriterFrom(buf, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, b, buf.Bytes())
+		})
+	}
+}
+
+func writeSave(w *psx.Writer, b []byte) error {
+	fw, err := w.Create()
+	if err != nil {
+		return err
+	}
+
+	if _, err := fw.Write(b); err != nil {
+		return err
+	}
+
+	return fw.Close()
+}
+
+//nolint:funlen
+func TestNewWriterFromAllocation(t *testing.T) {
+	t.Parallel()
+
+	// The last block is free, the three blocks before it hold a deleted
+	// file, and the header block has non-zero padding, broken sectors
+	// and replacement data for them
+	b, err := os.ReadFile(filepath.Join("testdata", "dirty.mcr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := psx.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, r.File, 9)
+
+	buf := new(bytes.Buffer)
+
+	w, err := psx.NewWriterFrom(buf, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := newSave("BASLUS-00000FIRST", 1), newSave("BASLUS-00000SECOND", 2)
+
+	// The free block is used first, then the deleted file is overwritten
+	for _, save := range [][]byte{first, second} {
+		if err := writeSave(w, save); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = writeSave(w, newSave("BASLUS-00000THIRD", 2))
+	assert.ErrorIs(t, err, psx.ErrNoFreeSpace)
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.Bytes()
+
+	// Everything other than the affected directory frames is unaltered
+	for _, frame := range []int{0, 16, 17, 36, 37, 63} {
+		assert.Equal(t, b[frame*frameSize:(frame+1)*frameSize], out[frame*frameSize:(frame+1)*frameSize])
+	}
+
+	assert.Equal(t, b[:11*frameSize], out[:11*frameSize])
+	assert.Equal(t, b[13*frameSize:15*frameSize], out[13*frameSize:15*frameSize])
+	assert.Equal(t, b[16*frameSize:blockSize], out[16*frameSize:blockSize])
+	assert.Equal(t, b[blockSize:11*blockSize], out[blockSize:11*blockSize])
+	assert.Equal(t, b[13*blockSize:15*blockSize], out[13*blockSize:15*blockSize])
+
+	r, err = psx.NewReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, r.File, 11)
+
+	frames := r.DirectoryFrames()
+	assert.Equal(t, psx.AllocationFirst, frames[14].Allocation)
+	assert.Equal(t, psx.AllocationFirst, frames[10].Allocation)
+	assert.Equal(t, psx.AllocationLast, frames[11].Allocation)
+	assert.Equal(t, psx.AllocationDeletedLast, frames[12].Allocation)
+
+	for name, want := range map[string][]byte{"BASLUS-00000FIRST": first, "BASLUS-00000SECOND": second} {
+		got, err := fs.ReadFile(r, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Only the link order differs
+		assert.Equal(t, want[frameSize:], got[frameSize:])
+	}
+}
+
+func TestRemoveAndReplace(t *testing.T) {
+	t.Parallel()
+
+	b, err := os.ReadFile(filepath.Join("testdata", "m1.mcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := psx.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+
+	w, err := psx.NewWriterFrom(buf, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Blocks 5 to 7
+	if err := w.Remove("BISLPS-00688EKD2-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.ErrorIs(t, w.Remove("BISLPS-00688EKD2-1"), fs.ErrNotExist)
+
+	_, err = w.Replace("BISLPS-00688EKD2-1")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	// Blocks 10 to 12, replaced by a smaller file with a new name
+	fw, err := w.Replace("BASLUS-01040VAG1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write(newSave("BASLUS-01040VAG2", 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = psx.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, r.File, 9)
+
+	allocations := make([]psx.Allocation, 0, 15)
+	for _, df := range r.DirectoryFrames() {
+		allocations = append(allocations, df.Allocation)
+	}
+
+	assert.Equal(t, []psx.Allocation{
+		psx.AllocationFirst, psx.AllocationFirst, psx.AllocationFirst, psx.AllocationFirst, psx.AllocationFirst,
+		psx.AllocationDeletedFirst, psx.AllocationDeletedMiddle, psx.AllocationDeletedLast,
+		psx.AllocationFirst, psx.AllocationFirst,
+		psx.AllocationFirst, psx.AllocationFree, psx.AllocationFree,
+		psx.AllocationFirst, psx.AllocationFree,
+	}, allocations)
+
+	// The removed file's data is still there
+	assert.Equal(t, b[6*blockSize:9*blockSize], buf.Bytes()[6*blockSize:9*blockSize])
+}